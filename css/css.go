@@ -17,37 +17,111 @@ type Token = scanner.Token
 
 type urlProcessor func(token *Token, data string, url *url.URL)
 
+// Reference is a URL reference that was found in CSS data.
+type Reference struct {
+	Token  *Token   // token that contains the reference
+	Value  string   // reference as written in the CSS data without quotes
+	URL    *url.URL // reference resolved against the base URL
+	Import bool     // whether the reference is the target of an @import rule
+}
+
 // Process the CSS data and call a processor for every found URL.
 func Process(logger *log.Logger, url *url.URL, data string, processor urlProcessor) {
+	Rewrite(logger, url, data, func(ref Reference) (string, bool) {
+		processor(ref.Token, ref.Value, ref.URL)
+		return "", false
+	})
+}
+
+// Rewrite calls the rewriter for every URL reference in the CSS data, which
+// includes url() values and the bare string form of @import rules. The URLs
+// of the references are resolved against the base URL. It returns the CSS
+// data with every reference replaced for which the rewriter returned a new
+// value, all other data is kept unchanged. Data after a scanner error like
+// an unclosed comment or string is kept without looking for references.
+func Rewrite(logger *log.Logger, base *url.URL, data string, rewriter func(ref Reference) (string, bool)) string {
 	css := scanner.New(data)
+	var result strings.Builder
+	var inImport bool
+	var consumed int // length of the data of all scanned tokens
 
 	for {
 		token := css.Next()
-		if token.Type == scanner.TokenEOF || token.Type == scanner.TokenError {
+		if token.Type == scanner.TokenEOF {
+			break
+		}
+		if token.Type == scanner.TokenError {
+			result.WriteString(data[min(consumed, len(data)):])
 			break
 		}
-		if token.Type != scanner.TokenURI {
-			continue
+		consumed += len(token.Value)
+
+		switch token.Type {
+		case scanner.TokenAtKeyword:
+			inImport = strings.EqualFold(token.Value, "@import")
+		case scanner.TokenS, scanner.TokenComment:
+		case scanner.TokenURI, scanner.TokenString:
+			isImport := inImport
+			inImport = false
+			if token.Type == scanner.TokenString && !isImport {
+				break // only the strings of @import rules are references
+			}
+
+			if replacement, ok := rewriteReference(logger, base, token, isImport, rewriter); ok {
+				result.WriteString(replacement)
+				continue
+			}
+		default:
+			inImport = false
 		}
 
+		result.WriteString(token.Value)
+	}
+
+	return result.String()
+}
+
+// rewriteReference calls the rewriter for the reference of the token and
+// returns the replacement token value if the rewriter returned a new value.
+func rewriteReference(logger *log.Logger, base *url.URL, token *Token, isImport bool,
+	rewriter func(ref Reference) (string, bool)) (string, bool) {
+
+	var src string
+	if token.Type == scanner.TokenURI {
 		match := cssURLRe.FindStringSubmatch(token.Value)
 		if match == nil {
-			continue
+			return "", false
 		}
+		src = strings.Trim(strings.TrimSpace(match[1]), `"'`)
+	} else {
+		src = token.Value[1 : len(token.Value)-1] // remove quotes
+	}
 
-		src := match[1]
-		if strings.HasPrefix(strings.ToLower(src), "data:") {
-			continue // skip embedded data
-		}
+	if src == "" || strings.HasPrefix(strings.ToLower(src), "data:") {
+		return "", false // skip embedded data
+	}
 
-		u, err := url.Parse(src)
-		if err != nil {
-			logger.Error("Parsing URL failed",
-				log.String("url", src),
-				log.Err(err))
-			continue
-		}
+	u, err := base.Parse(src)
+	if err != nil {
+		logger.Error("Parsing URL failed",
+			log.String("url", src),
+			log.Err(err))
+		return "", false
+	}
+
+	replacement, ok := rewriter(Reference{
+		Token:  token,
+		Value:  src,
+		URL:    u,
+		Import: isImport,
+	})
+	if !ok {
+		return "", false
+	}
 
-		processor(token, src, u)
+	if token.Type == scanner.TokenURI {
+		return "url('" + replacement + "')", true
 	}
+	quote := token.Value[:1]
+	return quote + replacement + quote, true
 }
@@ -0,0 +1,58 @@
+package css
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	base, err := url.Parse("https://example.org/css/style.css")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		refs     []string
+	}{
+		{
+			name:     "url and import",
+			input:    `@import "base.css"; body { background: url("/img/bg.png"); } .x { content: "text"; }`,
+			expected: `@import "local/css/base.css"; body { background: url('local/img/bg.png'); } .x { content: "text"; }`,
+			refs:     []string{"https://example.org/css/base.css", "https://example.org/img/bg.png"},
+		},
+		{
+			name:     "embedded data",
+			input:    `a { background: url(data:image/png;base64,AAAA); }`,
+			expected: `a { background: url(data:image/png;base64,AAAA); }`,
+		},
+		{
+			name:     "unclosed comment",
+			input:    `a { background: url(a.png); } /* b { background: url(b.png); }`,
+			expected: `a { background: url('local/css/a.png'); } /* b { background: url(b.png); }`,
+			refs:     []string{"https://example.org/css/a.png"},
+		},
+		{
+			name:     "unclosed string",
+			input:    "a { background: url(a.png); }\nb { content: \"unclosed; }\nc { background: url(c.png); }",
+			expected: "a { background: url('local/css/a.png'); }\nb { content: \"unclosed; }\nc { background: url(c.png); }",
+			refs:     []string{"https://example.org/css/a.png"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var refs []string
+			result := Rewrite(log.NewTestLogger(t), base, test.input, func(ref Reference) (string, bool) {
+				refs = append(refs, ref.URL.String())
+				return "local" + ref.URL.Path, true
+			})
+			assert.Equal(t, test.expected, result)
+			assert.Equal(t, test.refs, refs)
+		})
+	}
+}
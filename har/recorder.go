@@ -0,0 +1,391 @@
+package har
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedValue replaces the values of headers and cookies that contain
+// credentials.
+const RedactedValue = "[redacted]"
+
+// Recorder is an http.RoundTripper that records all HTTP exchanges of the
+// wrapped transport. Every request is recorded separately, redirects that
+// are followed by an http.Client therefore result in one entry per request.
+type Recorder struct {
+	transport http.RoundTripper
+	creator   Creator
+
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// NewRecorder returns a recorder that wraps the transport, the default
+// transport is used if it is nil.
+func NewRecorder(transport http.RoundTripper, creator Creator) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		transport: transport,
+		creator:   creator,
+	}
+}
+
+// RoundTrip executes the request using the wrapped transport and records
+// the exchange. The size of the response body is recorded once the body is
+// read completely or closed.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &exchangeTrace{}
+	started := time.Now()
+	ctx := httptrace.WithClientTrace(req.Context(), trace.clientTrace())
+
+	resp, err := r.transport.RoundTrip(req.WithContext(ctx))
+	received := time.Now()
+
+	entry := &Entry{
+		StartedDateTime: started,
+		Time:            milliseconds(received.Sub(started)),
+		Request:         trace.request(req),
+	}
+	entry.Timings, entry.ServerIPAddress = trace.timings(started, received)
+
+	if err != nil {
+		entry.Response = Response{
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		entry.Comment = err.Error()
+		r.add(entry)
+		return nil, err
+	}
+
+	entry.Response = newResponse(resp)
+	if resp.Proto != "" {
+		entry.Request.HTTPVersion = resp.Proto
+	}
+	r.add(entry)
+
+	resp.Body = &recordedBody{
+		ReadCloser:   resp.Body,
+		recorder:     r,
+		entry:        entry,
+		started:      started,
+		received:     received,
+		uncompressed: resp.Uncompressed,
+	}
+	return resp, nil
+}
+
+// File returns the HAR file containing all exchanges that were recorded so
+// far, ordered by their start time.
+func (r *Recorder) File() File {
+	r.mu.Lock()
+	entries := make([]Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, *entry)
+	}
+	r.mu.Unlock()
+
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return a.StartedDateTime.Compare(b.StartedDateTime)
+	})
+
+	return File{
+		Log: Log{
+			Version: Version,
+			Creator: r.creator,
+			Entries: entries,
+		},
+	}
+}
+
+func (r *Recorder) add(entry *Entry) {
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+// recordedBody counts the bytes of a response body and updates the entry
+// of the exchange once the body was read completely or closed.
+type recordedBody struct {
+	io.ReadCloser
+
+	recorder     *Recorder
+	entry        *Entry
+	started      time.Time
+	received     time.Time // time the response headers were received
+	uncompressed bool      // the body was decompressed by the transport
+
+	size     int64
+	finished bool
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	b.finish(nil)
+	return b.ReadCloser.Close()
+}
+
+func (b *recordedBody) finish(err error) {
+	if b.finished {
+		return
+	}
+	b.finished = true
+	now := time.Now()
+
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+
+	b.entry.Time = milliseconds(now.Sub(b.started))
+	b.entry.Timings.Receive = milliseconds(now.Sub(b.received))
+	b.entry.Response.Content.Size = b.size
+	if !b.uncompressed {
+		b.entry.Response.BodySize = b.size
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.entry.Comment = err.Error()
+	}
+}
+
+// exchangeTrace collects the header fields that were written and the times
+// of the connection phases of a request.
+type exchangeTrace struct {
+	mu            sync.Mutex
+	header        http.Header // written request header fields
+	dnsStart      time.Time
+	dnsDone       time.Time
+	connectStart  time.Time
+	connectDone   time.Time
+	tlsStart      time.Time
+	tlsDone       time.Time
+	gotConn       time.Time
+	wroteRequest  time.Time
+	remoteAddress string
+}
+
+func (t *exchangeTrace) clientTrace() *httptrace.ClientTrace {
+	record := func(field *time.Time) {
+		t.mu.Lock()
+		*field = time.Now()
+		t.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart:      func(string, string) { record(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { record(&t.connectDone) },
+		TLSHandshakeStart: func() { record(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { record(&t.wroteRequest) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.header = nil // the transport retries requests that failed on a reused connection
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				t.remoteAddress = host
+			}
+		},
+		WroteHeaderField: func(key string, value []string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.header == nil {
+				t.header = http.Header{}
+			}
+			t.header[key] = append(t.header[key], value...)
+		},
+	}
+}
+
+// request returns the recorded request. The header fields that were written
+// to the connection are used, which include the fields that the transport
+// adds, the request header is used if none were written.
+func (t *exchangeTrace) request(req *http.Request) Request {
+	t.mu.Lock()
+	header := t.header
+	t.mu.Unlock()
+	if header == nil {
+		header = req.Header
+	}
+
+	version := req.Proto
+	if version == "" {
+		version = "HTTP/1.1"
+	}
+
+	bodySize := req.ContentLength
+	if req.Body == nil || req.Body == http.NoBody {
+		bodySize = 0
+	}
+
+	return Request{
+		Method:      req.Method,
+		URL:         req.URL.Redacted(),
+		HTTPVersion: version,
+		Cookies:     newCookies((&http.Request{Header: header}).Cookies()),
+		Headers:     headerValues(header),
+		QueryString: queryValues(req.URL),
+		HeadersSize: -1,
+		BodySize:    bodySize,
+	}
+}
+
+// timings returns the timings of the exchange until the response headers
+// were received and the address of the server.
+func (t *exchangeTrace) timings(started, received time.Time) (Timings, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	connectDone := t.connectDone
+	if !t.tlsDone.IsZero() {
+		connectDone = t.tlsDone
+	}
+	timings := Timings{
+		Blocked: -1,
+		DNS:     span(t.dnsStart, t.dnsDone),
+		Connect: span(t.connectStart, connectDone),
+		SSL:     span(t.tlsStart, t.tlsDone),
+	}
+
+	sendStart := started
+	if !t.gotConn.IsZero() {
+		sendStart = t.gotConn
+		blocked := milliseconds(t.gotConn.Sub(started)) - max(timings.DNS, 0) - max(timings.Connect, 0)
+		timings.Blocked = max(blocked, 0)
+	}
+	waitStart := sendStart
+	if !t.wroteRequest.IsZero() {
+		timings.Send = milliseconds(t.wroteRequest.Sub(sendStart))
+		waitStart = t.wroteRequest
+	}
+	timings.Wait = milliseconds(received.Sub(waitStart))
+
+	return timings, t.remoteAddress
+}
+
+func newResponse(resp *http.Response) Response {
+	response := Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" "),
+		HTTPVersion: resp.Proto,
+		Cookies:     newCookies(resp.Cookies()),
+		Headers:     headerValues(resp.Header),
+		Content: Content{
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	if location, err := resp.Location(); err == nil {
+		response.RedirectURL = location.Redacted()
+	}
+	return response
+}
+
+// headerValues returns the header fields sorted by name, credentials in the
+// values are redacted.
+func headerValues(header http.Header) []NameValue {
+	values := []NameValue{}
+	for name, fieldValues := range header {
+		for _, value := range fieldValues {
+			values = append(values, NameValue{Name: name, Value: redactHeaderValue(name, value)})
+		}
+	}
+
+	slices.SortStableFunc(values, func(a, b NameValue) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return values
+}
+
+// redactHeaderValue returns the value of a header field with its credentials
+// replaced. The values of authorization fields are replaced completely, the
+// cookie fields keep the names of the cookies and the cookie attributes.
+func redactHeaderValue(name, value string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization":
+		return RedactedValue
+
+	case "Cookie":
+		cookies, err := http.ParseCookie(value)
+		if err != nil {
+			return RedactedValue
+		}
+		pairs := make([]string, 0, len(cookies))
+		for _, cookie := range cookies {
+			pairs = append(pairs, cookie.Name+"="+RedactedValue)
+		}
+		return strings.Join(pairs, "; ")
+
+	case "Set-Cookie":
+		pair, attributes, hasAttributes := strings.Cut(value, ";")
+		cookieName, _, _ := strings.Cut(pair, "=")
+		redacted := strings.TrimSpace(cookieName) + "=" + RedactedValue
+		if hasAttributes {
+			redacted += ";" + attributes
+		}
+		return redacted
+
+	default:
+		return value
+	}
+}
+
+func queryValues(u *url.URL) []NameValue {
+	values := []NameValue{}
+	for name, fieldValues := range u.Query() {
+		for _, value := range fieldValues {
+			values = append(values, NameValue{Name: name, Value: value})
+		}
+	}
+
+	slices.SortStableFunc(values, func(a, b NameValue) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return values
+}
+
+// newCookies returns the names of the cookies, their values are redacted.
+func newCookies(cookies []*http.Cookie) []Cookie {
+	result := make([]Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		result = append(result, Cookie{Name: cookie.Name, Value: RedactedValue})
+	}
+	return result
+}
+
+// span returns the duration between the times in milliseconds or -1 if one
+// of the times is not set.
+func span(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return milliseconds(end.Sub(start))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,152 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new?page=2", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, _ *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, "<html>new</html>")
+		assert.NoError(t, err)
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	recorder := NewRecorder(nil, Creator{Name: "goscrape", Version: "test"})
+	client := &http.Client{Transport: recorder}
+
+	req, err := http.NewRequest(http.MethodGet, svr.URL+"/old", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Basic c2VjcmV0")
+	req.Header.Set("User-Agent", "goscrape")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "<html>new</html>", string(body))
+
+	file := recorder.File()
+	assert.Equal(t, Version, file.Log.Version)
+	assert.Equal(t, Creator{Name: "goscrape", Version: "test"}, file.Log.Creator)
+	require.Len(t, file.Log.Entries, 2)
+
+	redirect := file.Log.Entries[0]
+	assert.Equal(t, http.MethodGet, redirect.Request.Method)
+	assert.Equal(t, svr.URL+"/old", redirect.Request.URL)
+	assert.Equal(t, "HTTP/1.1", redirect.Request.HTTPVersion)
+	assert.Contains(t, redirect.Request.Headers, NameValue{Name: "Authorization", Value: RedactedValue})
+	assert.Contains(t, redirect.Request.Headers, NameValue{Name: "User-Agent", Value: "goscrape"})
+	assert.Equal(t, http.StatusMovedPermanently, redirect.Response.Status)
+	assert.Equal(t, "Moved Permanently", redirect.Response.StatusText)
+	assert.Equal(t, svr.URL+"/new?page=2", redirect.Response.RedirectURL)
+	assert.Equal(t, "127.0.0.1", redirect.ServerIPAddress)
+
+	page := file.Log.Entries[1]
+	assert.Equal(t, svr.URL+"/new?page=2", page.Request.URL)
+	assert.Equal(t, []NameValue{{Name: "page", Value: "2"}}, page.Request.QueryString)
+	assert.Contains(t, page.Request.Headers, NameValue{Name: "Authorization", Value: RedactedValue})
+	assert.Equal(t, http.StatusOK, page.Response.Status)
+	assert.Empty(t, page.Response.RedirectURL)
+	assert.Equal(t, []Cookie{{Name: "session", Value: RedactedValue}}, page.Response.Cookies)
+	assert.Equal(t, Content{Size: int64(len(body)), MimeType: "text/html"}, page.Response.Content)
+	assert.Equal(t, int64(len(body)), page.Response.BodySize)
+	assert.GreaterOrEqual(t, page.Timings.Wait, 0.0)
+	assert.GreaterOrEqual(t, page.Timings.Receive, 0.0)
+	assert.Empty(t, page.Comment)
+
+	path := filepath.Join(t.TempDir(), "traffic.har")
+	require.NoError(t, file.WriteFile(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "c2VjcmV0")
+
+	var decoded File
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Len(t, decoded.Log.Entries, 2)
+}
+
+func TestRecorderRedactsCookies(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "refreshed", Value: "new-secret", Path: "/", HttpOnly: true})
+		_, err := fmt.Fprint(w, "ok")
+		assert.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "old-secret"},
+		{Name: "theme", Value: "dark"},
+	})
+
+	recorder := NewRecorder(nil, Creator{Name: "goscrape"})
+	client := &http.Client{Transport: recorder, Jar: jar}
+	resp, err := client.Get(svr.URL)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	file := recorder.File()
+	require.Len(t, file.Log.Entries, 1)
+	entry := file.Log.Entries[0]
+
+	assert.Equal(t, []Cookie{{Name: "session", Value: RedactedValue}, {Name: "theme", Value: RedactedValue}},
+		entry.Request.Cookies)
+	assert.Contains(t, entry.Request.Headers,
+		NameValue{Name: "Cookie", Value: "session=" + RedactedValue + "; theme=" + RedactedValue})
+	assert.Equal(t, []Cookie{{Name: "refreshed", Value: RedactedValue}}, entry.Response.Cookies)
+	assert.Contains(t, entry.Response.Headers,
+		NameValue{Name: "Set-Cookie", Value: "refreshed=" + RedactedValue + "; Path=/; HttpOnly"})
+
+	data, err := json.Marshal(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "old-secret")
+	assert.NotContains(t, string(data), "new-secret")
+	assert.NotContains(t, string(data), "dark")
+}
+
+func TestRecorderFailedRequest(t *testing.T) {
+	svr := httptest.NewServer(http.NotFoundHandler())
+	address := svr.URL
+	svr.Close()
+
+	recorder := NewRecorder(nil, Creator{Name: "goscrape"})
+	client := &http.Client{Transport: recorder}
+
+	resp, err := client.Get(address)
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+	require.Error(t, err)
+
+	file := recorder.File()
+	require.Len(t, file.Log.Entries, 1)
+	entry := file.Log.Entries[0]
+	assert.Equal(t, address, entry.Request.URL)
+	assert.Equal(t, 0, entry.Response.Status)
+	assert.NotEmpty(t, entry.Comment)
+}
@@ -31,7 +31,11 @@ type Node struct {
 // nolint: revive
 const (
 	BackgroundAttribute = "background"
+	ContentAttribute    = "content"
+	DataAttribute       = "data"
 	HrefAttribute       = "href"
+	PosterAttribute     = "poster"
+	StyleAttribute      = "style"
 
 	DataSrcAttribute = "data-src"
 	SrcAttribute     = "src"
@@ -42,38 +46,109 @@ const (
 
 // nolint: revive
 const (
-	ATag      = "a"
-	BodyTag   = "body"
-	ImgTag    = "img"
-	LinkTag   = "link"
-	ScriptTag = "script"
-	StyleTag  = "style"
+	ATag       = "a"
+	AudioTag   = "audio"
+	BaseTag    = "base"
+	BodyTag    = "body"
+	EmbedTag   = "embed"
+	FeImageTag = "feImage"
+	IframeTag  = "iframe"
+	ImageTag   = "image"
+	ImgTag     = "img"
+	InputTag   = "input"
+	LinkTag    = "link"
+	MetaTag    = "meta"
+	ObjectTag  = "object"
+	ScriptTag  = "script"
+	SourceTag  = "source"
+	StyleTag   = "style"
+	TrackTag   = "track"
+	UseTag     = "use"
+	VideoTag   = "video"
 )
 
+// InlineStyle is the index key of the references of style attributes, which
+// can be set on any HTML tag.
+const InlineStyle = "[style]"
+
 // Nodes describes the HTML tags and their attributes that can contain URL.
 var Nodes = map[string]Node{
 	ATag: {
 		Attributes: []string{HrefAttribute},
 	},
+	AudioTag: {
+		Attributes: []string{SrcAttribute},
+	},
+	BaseTag: {
+		Attributes: []string{HrefAttribute},
+	},
 	BodyTag: {
 		Attributes: []string{BackgroundAttribute},
 	},
+	EmbedTag: {
+		Attributes: []string{SrcAttribute},
+	},
+	FeImageTag: {
+		Attributes: []string{HrefAttribute},
+		parser:     svgReferenceParser,
+	},
+	IframeTag: {
+		Attributes: []string{SrcAttribute},
+	},
+	ImageTag: {
+		Attributes: []string{HrefAttribute},
+		parser:     svgReferenceParser,
+	},
 	ImgTag: {
 		Attributes: []string{SrcAttribute, DataSrcAttribute, SrcSetAttribute, DataSrcSetAttribute},
 		parser:     srcSetValueSplitter,
 	},
+	InputTag: {
+		Attributes: []string{SrcAttribute},
+		parser:     inputImageParser,
+	},
 	LinkTag: {
 		Attributes: []string{HrefAttribute},
+		parser:     linkRelationParser,
+	},
+	MetaTag: {
+		Attributes: []string{ContentAttribute},
+		parser:     metaRefreshParser,
+	},
+	ObjectTag: {
+		Attributes: []string{DataAttribute},
 	},
 	ScriptTag: {
 		Attributes: []string{SrcAttribute},
 	},
+	SourceTag: {
+		Attributes: []string{SrcAttribute, SrcSetAttribute, DataSrcSetAttribute},
+		parser:     srcSetValueSplitter,
+	},
 	StyleTag: {
 		noChildParsing: true,
 		parser:         styleParser,
 	},
+	TrackTag: {
+		Attributes: []string{SrcAttribute},
+	},
+	UseTag: {
+		Attributes: []string{HrefAttribute},
+		parser:     svgReferenceParser,
+	},
+	VideoTag: {
+		Attributes: []string{SrcAttribute, PosterAttribute},
+	},
 }
 
+// PageTags contains the tags whose references are crawled as pages. Link
+// tags are crawled as pages depending on their relation, see LinkRelation.
+var PageTags = set.NewFromSlice([]string{
+	ATag,
+	IframeTag,
+	MetaTag,
+})
+
 // SrcSetAttributes contains the attributes that contain srcset values.
 var SrcSetAttributes = set.NewFromSlice([]string{
 	DataSrcSetAttribute,
@@ -15,7 +15,8 @@ import (
 
 // Index provides an index for all HTML tags of relevance for scraping.
 type Index struct {
-	logger *log.Logger
+	logger  *log.Logger
+	baseURL *url.URL // URL of the base element of the document, if set
 
 	// key is HTML tag, value is a map of all its urls and the HTML nodes for it
 	data map[string]map[string][]*html.Node
@@ -29,8 +30,23 @@ func New(logger *log.Logger) *Index {
 	}
 }
 
-// Index the given HTML document.
+// Index the given HTML document. If the document contains a base element,
+// its URL is used as the base for all references of the document.
 func (idx *Index) Index(baseURL *url.URL, node *html.Node) {
+	if u := findBaseURL(baseURL, node); u != nil {
+		idx.baseURL = u
+		baseURL = u
+	}
+	idx.indexChildren(baseURL, node)
+}
+
+// BaseURL returns the URL of the base element of the indexed document or nil
+// if the document does not contain a base element.
+func (idx *Index) BaseURL() *url.URL {
+	return idx.baseURL
+}
+
+func (idx *Index) indexChildren(baseURL *url.URL, node *html.Node) {
 	for child := node.FirstChild; child != nil; child = child.NextSibling {
 		switch child.Type {
 		case html.ElementNode:
@@ -58,8 +74,62 @@ func (idx *Index) indexElementNode(baseURL *url.URL, node, child *html.Node) {
 		m[reference] = append(m[reference], child)
 	}
 
+	idx.indexStyleAttribute(baseURL, child)
+
 	if node.FirstChild != nil && !info.noChildParsing {
-		idx.Index(baseURL, child)
+		idx.indexChildren(baseURL, child)
+	}
+}
+
+// findBaseURL returns the resolved URL of the first base element of the
+// document that has a href attribute. Only http and https URLs are returned.
+func findBaseURL(documentURL *url.URL, node *html.Node) *url.URL {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+
+		if child.Data == BaseTag && child.Namespace == "" {
+			for _, attr := range child.Attr {
+				if attr.Key != HrefAttribute {
+					continue
+				}
+
+				u, err := documentURL.Parse(strings.TrimSpace(attr.Val))
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+					return nil
+				}
+				u.Fragment = ""
+				return u
+			}
+		}
+
+		if u := findBaseURL(documentURL, child); u != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// indexStyleAttribute indexes the references of the CSS data of the style
+// attribute of the node.
+func (idx *Index) indexStyleAttribute(baseURL *url.URL, node *html.Node) {
+	for _, attr := range node.Attr {
+		if attr.Key != StyleAttribute || attr.Namespace != "" {
+			continue
+		}
+
+		m, ok := idx.data[InlineStyle]
+		if !ok {
+			m = map[string][]*html.Node{}
+			idx.data[InlineStyle] = m
+		}
+
+		processor := func(_ *css.Token, _ string, url *url.URL) {
+			reference := url.String()
+			m[reference] = append(m[reference], node)
+		}
+		css.Process(idx.logger, baseURL, attr.Val, processor)
 	}
 }
 
@@ -74,9 +144,29 @@ func (idx *Index) URLs(tag string) ([]*url.URL, error) {
 	for key := range m {
 		data = append(data, key)
 	}
+	return parseURLs(data)
+}
+
+// LinkURLs returns all URLs of the references of link tags that have the
+// given relation, see LinkRelation.
+func (idx *Index) LinkURLs(relation string) ([]*url.URL, error) {
+	var data []string
+	for key, nodes := range idx.data[LinkTag] {
+		for _, node := range nodes {
+			if LinkRelation(node) == relation {
+				data = append(data, key)
+				break
+			}
+		}
+	}
+	return parseURLs(data)
+}
+
+// parseURLs parses the given URLs and returns them sorted.
+func parseURLs(data []string) ([]*url.URL, error) {
 	sort.Strings(data)
 
-	urls := make([]*url.URL, 0, len(m))
+	urls := make([]*url.URL, 0, len(data))
 	for _, fullURL := range data {
 		u, err := url.Parse(fullURL)
 		if err != nil {
@@ -172,6 +262,26 @@ func srcSetValueSplitter(data nodeAttributeParserData) ([]string, bool) {
 	return values, true
 }
 
+// inputImageParser skips the src attribute of input nodes that are not of
+// the image type.
+func inputImageParser(data nodeAttributeParserData) ([]string, bool) {
+	for _, attr := range data.node.Attr {
+		if attr.Key == "type" && strings.EqualFold(strings.TrimSpace(attr.Val), "image") {
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+// svgReferenceParser skips the references of SVG nodes that point to a
+// fragment of the same document, for example symbols of an inline sprite.
+func svgReferenceParser(data nodeAttributeParserData) ([]string, bool) {
+	if data.value == "" || strings.HasPrefix(data.value, "#") {
+		return nil, true
+	}
+	return nil, false
+}
+
 // styleParser returns the URL values of a CSS style tag.
 func styleParser(data nodeAttributeParserData) ([]string, bool) {
 	if data.node.FirstChild == nil {
@@ -79,6 +79,127 @@ func TestIndexImg(t *testing.T) {
 	assert.Equal(t, "https://domain.com/bg.jpg", references[0].String())
 }
 
+func TestIndexMedia(t *testing.T) {
+	input := []byte(`
+<html>
+<body>
+<video src="movie.mp4" poster="poster.jpg">
+  <source src="movie.webm" type="video/webm">
+  <track src="subtitles.vtt" kind="subtitles">
+</video>
+<audio src="sound.mp3"></audio>
+<picture>
+  <source srcset="small.webp 480w, large.webp 800w">
+  <img src="fallback.jpg">
+</picture>
+<iframe src="/frame"></iframe>
+<embed src="flash.swf">
+<object data="document.pdf"></object>
+<input type="image" src="button.png">
+<input type="text" src="ignored.png">
+</body>
+</html>
+`)
+
+	idx := testSetup(t, input)
+
+	tests := map[string][]string{
+		VideoTag:  {"https://domain.com/movie.mp4", "https://domain.com/poster.jpg"},
+		SourceTag: {"https://domain.com/large.webp", "https://domain.com/movie.webm", "https://domain.com/small.webp"},
+		TrackTag:  {"https://domain.com/subtitles.vtt"},
+		AudioTag:  {"https://domain.com/sound.mp3"},
+		IframeTag: {"https://domain.com/frame"},
+		EmbedTag:  {"https://domain.com/flash.swf"},
+		ObjectTag: {"https://domain.com/document.pdf"},
+		InputTag:  {"https://domain.com/button.png"},
+	}
+
+	for tag, expected := range tests {
+		references, err := idx.URLs(tag)
+		require.NoError(t, err)
+
+		urls := make([]string, 0, len(references))
+		for _, reference := range references {
+			urls = append(urls, reference.String())
+		}
+		assert.Equal(t, expected, urls, tag)
+	}
+}
+
+func TestIndexStyleAndSVG(t *testing.T) {
+	input := []byte(`
+<html>
+<body>
+<div class="hero" style="background-image: url('/img/hero.jpg')">
+  <p style="color: red">text</p>
+  <span style="background: url(a.png), url(data:image/gif;base64,R0lGODl)"></span>
+</div>
+<svg xmlns:xlink="http://www.w3.org/1999/xlink">
+  <image href="chart.png"/>
+  <use xlink:href="sprite.svg#icon"/>
+  <use href="#local"/>
+  <filter><feImage href="texture.png"/></filter>
+</svg>
+</body>
+</html>
+`)
+
+	idx := testSetup(t, input)
+
+	tests := map[string][]string{
+		InlineStyle: {"https://domain.com/a.png", "https://domain.com/img/hero.jpg"},
+		ImageTag:    {"https://domain.com/chart.png"},
+		UseTag:      {"https://domain.com/sprite.svg#icon"},
+		FeImageTag:  {"https://domain.com/texture.png"},
+	}
+
+	for tag, expected := range tests {
+		references, err := idx.URLs(tag)
+		require.NoError(t, err)
+
+		urls := make([]string, 0, len(references))
+		for _, reference := range references {
+			urls = append(urls, reference.String())
+		}
+		assert.Equal(t, expected, urls, tag)
+	}
+}
+
+func TestIndexBase(t *testing.T) {
+	input := []byte(`
+<html>
+<head><base href="/app/" target="_blank"></head>
+<body>
+<a href="page">Page</a>
+<img src="img/logo.png">
+<div style="background: url(bg.jpg)"></div>
+</body>
+</html>
+`)
+
+	idx := testSetup(t, input)
+	require.NotNil(t, idx.BaseURL())
+	assert.Equal(t, "https://domain.com/app/", idx.BaseURL().String())
+
+	references, err := idx.URLs(ATag)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	assert.Equal(t, "https://domain.com/app/page", references[0].String())
+
+	references, err = idx.URLs(ImgTag)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	assert.Equal(t, "https://domain.com/app/img/logo.png", references[0].String())
+
+	references, err = idx.URLs(InlineStyle)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	assert.Equal(t, "https://domain.com/app/bg.jpg", references[0].String())
+
+	idx = testSetup(t, []byte(`<html><head><base target="_blank"></head></html>`))
+	assert.Nil(t, idx.BaseURL())
+}
+
 func testSetup(t *testing.T, input []byte) *Index {
 	t.Helper()
 
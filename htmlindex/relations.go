@@ -0,0 +1,127 @@
+package htmlindex
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// nolint: revive
+const (
+	LinkRelationAsset      = "asset"
+	LinkRelationPage       = "page"
+	LinkRelationStylesheet = "stylesheet"
+)
+
+// linkRelations maps the values of the rel attribute of link tags to the
+// handling of the referenced URL. Relations that are not listed, like
+// preconnect or dns-prefetch, do not reference a file to download.
+var linkRelations = map[string]string{
+	"alternate":                    LinkRelationPage,
+	"canonical":                    LinkRelationPage,
+	"next":                         LinkRelationPage,
+	"prev":                         LinkRelationPage,
+	"previous":                     LinkRelationPage,
+	"apple-touch-icon":             LinkRelationAsset,
+	"apple-touch-icon-precomposed": LinkRelationAsset,
+	"icon":                         LinkRelationAsset,
+	"manifest":                     LinkRelationAsset,
+	"mask-icon":                    LinkRelationAsset,
+	"modulepreload":                LinkRelationAsset,
+	"preload":                      LinkRelationAsset,
+	"stylesheet":                   LinkRelationStylesheet,
+}
+
+// LinkRelation returns how the reference of a link node is handled, which is
+// one of the LinkRelation constants or an empty string if the reference is
+// not followed. A stylesheet relation takes precedence over others, this
+// covers alternate stylesheets and stylesheets that are preloaded.
+func LinkRelation(node *html.Node) string {
+	var rel, as string
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "rel":
+			rel = attr.Val
+		case "as":
+			as = attr.Val
+		}
+	}
+
+	var relation string
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		switch linkRelations[value] {
+		case LinkRelationStylesheet:
+			return LinkRelationStylesheet
+		case LinkRelationAsset:
+			if value == "preload" && strings.EqualFold(strings.TrimSpace(as), "style") {
+				return LinkRelationStylesheet
+			}
+			relation = LinkRelationAsset
+		case LinkRelationPage:
+			if relation == "" {
+				relation = LinkRelationPage
+			}
+		}
+	}
+	return relation
+}
+
+// linkRelationParser skips the references of link nodes whose relation does
+// not reference a file to download.
+func linkRelationParser(data nodeAttributeParserData) ([]string, bool) {
+	if LinkRelation(data.node) == "" {
+		return nil, true
+	}
+	return nil, false
+}
+
+// metaRefreshParser returns the URL of meta refresh nodes.
+func metaRefreshParser(data nodeAttributeParserData) ([]string, bool) {
+	if !isMetaRefresh(data.node) {
+		return nil, true
+	}
+
+	_, reference, ok := ParseMetaRefresh(data.value)
+	if !ok {
+		return nil, true
+	}
+	return []string{reference}, true
+}
+
+// isMetaRefresh returns whether the node is a meta node with the http-equiv
+// attribute set to refresh.
+func isMetaRefresh(node *html.Node) bool {
+	for _, attr := range node.Attr {
+		if attr.Key == "http-equiv" && strings.EqualFold(strings.TrimSpace(attr.Val), "refresh") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMetaRefresh parses the content attribute value of a meta refresh node
+// like "5; url=/next" and returns the delay and the URL. It returns false if
+// the value does not contain a URL.
+func ParseMetaRefresh(content string) (string, string, bool) {
+	delay, reference, found := strings.Cut(content, ";")
+	if !found {
+		delay, reference, found = strings.Cut(content, ",")
+	}
+	if !found {
+		return "", "", false
+	}
+
+	reference = strings.TrimSpace(reference)
+	if len(reference) >= 3 && strings.EqualFold(reference[:3], "url") {
+		rest := strings.TrimSpace(reference[3:])
+		if strings.HasPrefix(rest, "=") {
+			reference = strings.TrimSpace(rest[1:])
+		}
+	}
+	reference = strings.Trim(reference, `"'`)
+	if reference == "" {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(delay), reference, true
+}
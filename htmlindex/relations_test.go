@@ -0,0 +1,100 @@
+package htmlindex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestLinkRelation(t *testing.T) {
+	tests := []struct {
+		attributes []html.Attribute
+		expected   string
+	}{
+		{[]html.Attribute{{Key: "rel", Val: "stylesheet"}}, LinkRelationStylesheet},
+		{[]html.Attribute{{Key: "rel", Val: "alternate stylesheet"}}, LinkRelationStylesheet},
+		{[]html.Attribute{{Key: "rel", Val: "Canonical"}}, LinkRelationPage},
+		{[]html.Attribute{{Key: "rel", Val: "next"}}, LinkRelationPage},
+		{[]html.Attribute{{Key: "rel", Val: "shortcut icon"}}, LinkRelationAsset},
+		{[]html.Attribute{{Key: "rel", Val: "manifest"}}, LinkRelationAsset},
+		{[]html.Attribute{{Key: "rel", Val: "preload"}, {Key: "as", Val: "font"}}, LinkRelationAsset},
+		{[]html.Attribute{{Key: "rel", Val: "preload"}, {Key: "as", Val: "style"}}, LinkRelationStylesheet},
+		{[]html.Attribute{{Key: "rel", Val: "preconnect"}}, ""},
+		{nil, ""},
+	}
+
+	for _, test := range tests {
+		node := &html.Node{Type: html.ElementNode, Data: LinkTag, Attr: test.attributes}
+		assert.Equal(t, test.expected, LinkRelation(node), test.attributes)
+	}
+}
+
+func TestParseMetaRefresh(t *testing.T) {
+	tests := []struct {
+		content   string
+		delay     string
+		reference string
+		ok        bool
+	}{
+		{"0; url=https://domain.com/next", "0", "https://domain.com/next", true},
+		{"5;URL='/next'", "5", "/next", true},
+		{"3, url = next.html", "3", "next.html", true},
+		{"0; /next", "0", "/next", true},
+		{"10", "", "", false},
+		{"0; url=", "", "", false},
+	}
+
+	for _, test := range tests {
+		delay, reference, ok := ParseMetaRefresh(test.content)
+		assert.Equal(t, test.ok, ok, test.content)
+		assert.Equal(t, test.delay, delay, test.content)
+		assert.Equal(t, test.reference, reference, test.content)
+	}
+}
+
+func TestIndexLinkRelations(t *testing.T) {
+	input := []byte(`
+<html>
+<head>
+<meta http-equiv="refresh" content="0; url=/moved">
+<meta name="viewport" content="width=device-width">
+<link rel="stylesheet" href="style.css">
+<link rel="canonical" href="https://domain.com/page">
+<link rel="next" href="/page/2">
+<link rel="icon" href="favicon.ico">
+<link rel="manifest" href="site.webmanifest">
+<link rel="dns-prefetch" href="https://cdn.domain.com">
+</head>
+</html>
+`)
+
+	idx := testSetup(t, input)
+
+	references, err := idx.URLs(MetaTag)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	assert.Equal(t, "https://domain.com/moved", references[0].String())
+
+	tests := map[string][]string{
+		LinkRelationStylesheet: {"https://domain.com/style.css"},
+		LinkRelationPage:       {"https://domain.com/page", "https://domain.com/page/2"},
+		LinkRelationAsset:      {"https://domain.com/favicon.ico", "https://domain.com/site.webmanifest"},
+	}
+
+	for relation, expected := range tests {
+		references, err := idx.LinkURLs(relation)
+		require.NoError(t, err)
+
+		urls := make([]string, 0, len(references))
+		for _, reference := range references {
+			urls = append(urls, reference.String())
+		}
+		assert.Equal(t, expected, urls, relation)
+	}
+
+	references, err = idx.URLs(LinkTag)
+	require.NoError(t, err)
+	assert.Len(t, references, 5)
+}
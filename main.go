@@ -6,11 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/cornelk/goscrape/scraper"
+	"github.com/cornelk/goscrape/storage"
 	"github.com/cornelk/gotokit/app"
 	"github.com/cornelk/gotokit/buildinfo"
 	"github.com/cornelk/gotokit/env"
@@ -29,16 +33,68 @@ type arguments struct {
 	Output  string   `arg:"-o,--output" help:"output directory to write files to"`
 	URLs    []string `arg:"positional"`
 
+	PageRules  []string `arg:"--pagerule" help:"rule that includes or excludes pages like 'exclude query~replytocom', see README"`
+	AssetRules []string `arg:"--assetrule" help:"rule that includes or excludes assets like 'exclude type=application/zip size>10MB', see README"`
+	Explain    string   `arg:"--explain" help:"print which rule decides whether a URL is downloaded without downloading anything"`
+
+	IgnoreQuery []string `arg:"--ignorequery" help:"query parameter to ignore for file names and duplicate detection, utm_* matches all parameters with the prefix"`
+
+	Domains             []string `arg:"--domain" help:"domain to download pages from besides the host of the URL, *.example.com matches all subdomains"`
+	AssetDomains        []string `arg:"--assetdomain" help:"domain to download assets from, assets of all domains are downloaded if not set"`
+	ExcludeAssetDomains []string `arg:"--excludeassetdomain" help:"domain to not download assets from"`
+	SpanHosts           int64    `arg:"--spanhosts" help:"number of links to follow to pages of other domains, 0 to disable"`
+
+	Concurrency  int64 `arg:"--concurrency" help:"number of concurrent downloads" default:"1"`
 	Depth        int64 `arg:"-d,--depth" help:"download depth, 0 for unlimited" default:"10"`
 	ImageQuality int64 `arg:"-i,--imagequality" help:"image quality, 0 to disable reencoding"`
 	Timeout      int64 `arg:"-t,--timeout" help:"time limit in seconds for each HTTP request to connect and read the request body"`
 
-	Serve      string `arg:"-s,--serve" help:"serve the website using a webserver"`
+	MaxResponseSize int64         `arg:"--maxresponsesize" help:"maximum size in MB of a response, larger responses are skipped, 0 for unlimited"`
+	MaxPages        int64         `arg:"--maxpages" help:"number of pages after which the scrape stops, 0 for unlimited"`
+	MaxAssets       int64         `arg:"--maxassets" help:"number of assets after which the scrape stops, 0 for unlimited"`
+	MaxTotalSize    int64         `arg:"--maxtotalsize" help:"total size in MB of downloads after which the scrape stops, 0 for unlimited"`
+	MaxDuration     time.Duration `arg:"--maxduration" help:"duration after which the scrape stops, for example 2h, 0 for unlimited"`
+
+	Serve      string `arg:"-s,--serve" help:"serve the website of a directory or archive using a webserver"`
 	ServerPort int16  `arg:"-r,--serverport" help:"port to use for the webserver" default:"8080"`
 
 	CookieFile     string `arg:"-c,--cookiefile" help:"file containing the cookie content"`
 	SaveCookieFile string `arg:"--savecookiefile" help:"file to save the cookie content"`
 
+	RateLimit       float64       `arg:"--ratelimit" help:"maximum requests per second per host, 0 for unlimited"`
+	Delay           time.Duration `arg:"--delay" help:"minimum delay between requests to the same host, for example 500ms"`
+	DelayJitter     time.Duration `arg:"--delayjitter" help:"maximum random delay added to the delay between requests"`
+	HostConnections int64         `arg:"--hostconnections" help:"maximum concurrent requests per host, 0 for unlimited"`
+	IgnoreRobots    bool          `arg:"--ignore-robots" help:"ignore the robots.txt files of the scraped hosts"`
+
+	MaxAttempts        int64         `arg:"--maxattempts" help:"maximum number of attempts of a failed request, 1 to disable retries" default:"10"`
+	RetryDelay         time.Duration `arg:"--retrydelay" help:"delay before the first retry, doubled on each further retry" default:"1s"`
+	RetryMaxDelay      time.Duration `arg:"--retrymaxdelay" help:"maximum delay between retries, also limits delays requested by Retry-After" default:"1m"`
+	RetryJitter        float64       `arg:"--retryjitter" help:"fraction of the retry delay that is randomized, from 0 to 1" default:"0.2"`
+	RetryStatus        []int         `arg:"--retrystatus" help:"HTTP status code to retry, 408, 429, 500, 502, 503 and 504 if not set"`
+	RetryNetworkErrors bool          `arg:"--retrynetworkerrors" help:"retry connection errors, timeouts and interrupted responses" default:"true"`
+
+	Sitemaps         []string `arg:"--sitemap" help:"sitemap URL to seed the crawl with"`
+	DiscoverSitemaps bool     `arg:"--discoversitemaps" help:"seed the crawl with the sitemaps listed in robots.txt"`
+
+	Resume      bool `arg:"--resume" help:"continue an interrupted crawl from the crawl state in the output directory"`
+	Incremental bool `arg:"--incremental" help:"only download files again that changed since the last scrape"`
+
+	WARC     string `arg:"--warc" help:"directory to write WARC files of all HTTP requests and responses to"`
+	WARCSize int64  `arg:"--warcsize" help:"size in MB after which a new WARC file is started" default:"1024"`
+	WARCOnly bool   `arg:"--warconly" help:"only write WARC files and no browsable website to the output directory"`
+
+	HAR string `arg:"--har" help:"file to write all HTTP requests and responses to in HAR format"`
+
+	Archive string `arg:"--archive" help:"write the scraped files to a .zip or .tar.gz archive instead of the output directory"`
+
+	AcceptStatus []int `arg:"--acceptstatus" help:"HTTP status code besides 200 of responses to store, 203 if not set"`
+	ErrorPage    bool  `arg:"--errorpage" help:"store the first 404 or 410 page of the website as 404.html, which is served for missing files"`
+
+	Report          string `arg:"--report" help:"file to write the crawl report listing all downloaded URLs in JSON format to"`
+	MaxFailures     int64  `arg:"--maxfailures" help:"number of failed URLs above which goscrape exits with the failure exit code, -1 to disable" default:"-1"`
+	FailureExitCode int    `arg:"--failureexitcode" help:"exit code to use if the number of failed URLs exceeds the maximum" default:"2"`
+
 	Headers   []string `arg:"-h,--header" help:"HTTP header to use for scraping"`
 	Proxy     string   `arg:"-p,--proxy" help:"proxy to use in format scheme://[user:password@]host:port (supports HTTP, HTTPS, SOCKS5 protocols)"`
 	User      string   `arg:"-u,--user" help:"user[:password] to use for HTTP authentication"`
@@ -83,6 +139,10 @@ func main() {
 
 	if err := runScraper(ctx, args, logger); err != nil {
 		fmt.Printf("Scraping execution error: %s\n", err)
+		var failuresErr *failuresError
+		if errors.As(err, &failuresErr) {
+			os.Exit(args.FailureExitCode)
+		}
 		os.Exit(1)
 	}
 }
@@ -139,14 +199,35 @@ func runScraper(ctx context.Context, args arguments, logger *log.Logger) error {
 		return fmt.Errorf("reading cookie: %w", err)
 	}
 
+	concurrency := args.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	cfg := scraper.Config{
-		Includes: args.Include,
-		Excludes: args.Exclude,
+		Includes:   args.Include,
+		Excludes:   args.Exclude,
+		PageRules:  args.PageRules,
+		AssetRules: args.AssetRules,
+
+		IgnoredQueryParameters: args.IgnoreQuery,
+
+		PageDomains:          args.Domains,
+		AssetDomains:         args.AssetDomains,
+		ExcludedAssetDomains: args.ExcludeAssetDomains,
+		SpanHostsDepth:       uint(max(args.SpanHosts, 0)),
 
+		Concurrency:  uint(concurrency),
 		ImageQuality: uint(imageQuality),
 		MaxDepth:     uint(args.Depth),
 		Timeout:      uint(args.Timeout),
 
+		MaxResponseSize: max(args.MaxResponseSize, 0) << 20,
+		MaxPages:        uint(max(args.MaxPages, 0)),
+		MaxAssets:       uint(max(args.MaxAssets, 0)),
+		MaxTotalSize:    max(args.MaxTotalSize, 0) << 20,
+		MaxDuration:     args.MaxDuration,
+
 		OutputDirectory: args.Output,
 		Username:        username,
 		Password:        password,
@@ -155,37 +236,178 @@ func runScraper(ctx context.Context, args arguments, logger *log.Logger) error {
 		Header:    scraper.Headers(args.Headers),
 		Proxy:     args.Proxy,
 		UserAgent: args.UserAgent,
+
+		RequestsPerSecond:     args.RateLimit,
+		CrawlDelay:            args.Delay,
+		CrawlDelayJitter:      args.DelayJitter,
+		MaxConnectionsPerHost: uint(max(args.HostConnections, 0)),
+		IgnoreRobotsTxt:       args.IgnoreRobots,
+
+		AcceptedStatusCodes: args.AcceptStatus,
+		ErrorPage:           args.ErrorPage,
+
+		MaxAttempts:        uint(max(args.MaxAttempts, 0)),
+		RetryDelay:         args.RetryDelay,
+		RetryMaxDelay:      args.RetryMaxDelay,
+		RetryJitter:        args.RetryJitter,
+		RetryStatusCodes:   args.RetryStatus,
+		RetryNetworkErrors: args.RetryNetworkErrors,
+
+		Sitemaps:         args.Sitemaps,
+		DiscoverSitemaps: args.DiscoverSitemaps,
+
+		Resume:      args.Resume,
+		Incremental: args.Incremental,
+
+		WARCDirectory:   args.WARC,
+		WARCMaxFileSize: args.WARCSize << 20,
+		WARCOnly:        args.WARCOnly,
+	}
+
+	if args.Explain != "" {
+		return explainURL(cfg, logger, args)
 	}
+	if args.Archive == "" {
+		reports, err := scrapeURLs(ctx, cfg, logger, args)
+		return reportCrawls(args, reports, err)
+	}
+
+	if args.Resume || args.Incremental {
+		// the archive storage starts empty, the files and the crawl state of a
+		// previous scrape are not available
+		return errors.New("--resume and --incremental can not be used together with --archive")
+	}
+
+	archive, err := storage.NewArchive(args.Archive, args.Output)
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+	cfg.Storage = archive
+
+	reports, err := scrapeURLs(ctx, cfg, logger, args)
+	// write the files that were scraped before an interruption as well
+	if closeErr := archive.Close(); closeErr != nil {
+		return fmt.Errorf("writing archive: %w", closeErr)
+	}
+	return reportCrawls(args, reports, err)
+}
 
-	return scrapeURLs(ctx, cfg, logger, args)
+// failuresError is returned if the number of failed URLs of the crawls
+// exceeds the maximum.
+type failuresError struct {
+	failures int
+	max      int64
 }
 
+func (e *failuresError) Error() string {
+	return fmt.Sprintf("%d URLs failed, the maximum is %d", e.failures, e.max)
+}
+
+// scrapeURLs scrapes all URLs and returns the reports of the started crawls,
+// including an interrupted or failed one.
 func scrapeURLs(ctx context.Context, cfg scraper.Config,
-	logger *log.Logger, args arguments) error {
+	logger *log.Logger, args arguments) ([]scraper.Report, error) {
 
-	for _, url := range args.URLs {
+	var reports []scraper.Report
+	for i, url := range args.URLs {
 		cfg.URL = url
+		cfg.HARFile = harFilePath(args.HAR, i, len(args.URLs))
 		sc, err := scraper.New(logger, cfg)
 		if err != nil {
-			return fmt.Errorf("initializing scraper: %w", err)
+			return reports, fmt.Errorf("initializing scraper: %w", err)
 		}
 
 		logger.Info("Scraping", log.String("url", sc.URL.String()))
-		if err = sc.Start(ctx); err != nil {
+		err = sc.Start(ctx)
+		reports = append(reports, sc.Report())
+		if err != nil {
 			if errors.Is(err, context.Canceled) {
-				os.Exit(0)
+				logger.Info("Scraping interrupted, use --resume to continue the crawl")
+				return reports, nil
 			}
 
-			return fmt.Errorf("scraping '%s': %w", sc.URL, err)
+			return reports, fmt.Errorf("scraping '%s': %w", sc.URL, err)
 		}
 
 		if args.SaveCookieFile != "" {
 			if err := saveCookies(args.SaveCookieFile, sc.Cookies()); err != nil {
-				return fmt.Errorf("saving cookies: %w", err)
+				return reports, fmt.Errorf("saving cookies: %w", err)
 			}
 		}
 	}
 
+	return reports, nil
+}
+
+// harFilePath returns the HAR file to write for the crawl of the URL with
+// the given index. The files are numbered if several URLs are scraped, as
+// every crawl writes its own file.
+func harFilePath(filePath string, index, urls int) string {
+	if filePath == "" || urls < 2 {
+		return filePath
+	}
+	ext := filepath.Ext(filePath)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filePath, ext), index+1, ext)
+}
+
+// reportCrawls prints the summaries of the crawl reports and writes them to
+// the report file. It returns a failuresError if more URLs failed than the
+// maximum allows.
+func reportCrawls(args arguments, reports []scraper.Report, err error) error {
+	for _, report := range reports {
+		fmt.Print(report.Summary())
+	}
+	if args.Report != "" && len(reports) > 0 {
+		if writeErr := writeReports(args.Report, reports); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var failures int
+	for _, report := range reports {
+		failures += report.Failures
+	}
+	if args.MaxFailures >= 0 && int64(failures) > args.MaxFailures {
+		return &failuresError{failures: failures, max: args.MaxFailures}
+	}
+	return nil
+}
+
+// writeReports writes the reports of the crawls as JSON array to the file.
+func writeReports(filePath string, reports []scraper.Report) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding crawl report: %w", err)
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("writing crawl report: %w", err)
+	}
+	return nil
+}
+
+// explainURL prints for every given start URL which rules decide whether
+// the URL to explain is downloaded.
+func explainURL(cfg scraper.Config, logger *log.Logger, args arguments) error {
+	u, err := url.Parse(args.Explain)
+	if err != nil {
+		return fmt.Errorf("parsing URL to explain: %w", err)
+	}
+
+	for _, startURL := range args.URLs {
+		cfg.URL = startURL
+		sc, err := scraper.New(logger, cfg)
+		if err != nil {
+			return fmt.Errorf("initializing scraper: %w", err)
+		}
+
+		fmt.Printf("%s linked from %s:\n", u, sc.URL)
+		for _, line := range sc.Explain(u) {
+			fmt.Printf("  %s\n", line)
+		}
+	}
 	return nil
 }
 
@@ -2,6 +2,8 @@
 package scraper
 
 import (
+	"context"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -21,49 +23,34 @@ func normalizeURLPath(path string) string {
 	return path
 }
 
-// shouldURLBeDownloaded checks whether a page should be downloaded.
+// shouldURLBeDownloaded checks whether a page or asset should be downloaded.
 // nolint: cyclop
-func (s *Scraper) shouldURLBeDownloaded(url *url.URL, currentDepth uint, isAsset bool) bool {
+func (s *Scraper) shouldURLBeDownloaded(ctx context.Context, target ruleTarget, isAsset bool) bool {
+	url := target.url
 	if url.Scheme != "http" && url.Scheme != "https" {
 		return false
 	}
 
-	p := url.String()
-	if url.Host == s.URL.Host {
-		p = url.Path
-	}
-	if p == "" {
-		p = "/"
-	}
-
-	// Normalize the path for duplicate detection to handle trailing slashes
-	normalizedPath := normalizeURLPath(p)
-
-	if s.processed.Contains(normalizedPath) { // was already downloaded or checked?
-		if url.Fragment != "" {
-			return false
-		}
+	if !s.markProcessed(s.processedKey(url)) { // was already downloaded or checked?
 		return false
 	}
 
-	s.processed.Add(normalizedPath)
-
-	if !isAsset {
-		if url.Host != s.URL.Host {
-			s.logger.Debug("Skipping external host page", log.String("url", url.String()))
+	if isAsset {
+		if !s.domains.isAssetHostAllowed(s.URL.Host, url.Host) {
+			s.logger.Debug("Skipping asset of excluded host", log.String("url", url.String()))
 			return false
 		}
-
-		if s.config.MaxDepth != 0 && currentDepth == s.config.MaxDepth {
+	} else {
+		if s.config.MaxDepth != 0 && target.depth > s.config.MaxDepth {
 			s.logger.Debug("Skipping too deep level page", log.String("url", url.String()))
 			return false
 		}
 	}
 
-	if s.includes != nil && !s.isURLIncluded(url) {
+	if !s.isURLAllowedByRules(target, isAsset) {
 		return false
 	}
-	if s.excludes != nil && s.isURLExcluded(url) {
+	if !s.isURLAllowedByRobots(ctx, url) {
 		return false
 	}
 
@@ -71,26 +58,148 @@ func (s *Scraper) shouldURLBeDownloaded(url *url.URL, currentDepth uint, isAsset
 	return true
 }
 
-func (s *Scraper) isURLIncluded(url *url.URL) bool {
-	for _, re := range s.includes {
-		if re.MatchString(url.Path) {
-			s.logger.Info("Including URL",
-				log.String("url", url.String()),
-				log.Stringer("included_expression", re))
-			return true
-		}
+// processedKey returns the key of the URL in the processed set. URLs of the
+// main host are stored by path, URLs of other hosts by the full URL. The
+// normalized query is appended to both.
+func (s *Scraper) processedKey(url *url.URL) string {
+	p := url.Path
+	if url.Host != s.URL.Host {
+		external := *url
+		external.RawQuery = ""
+		external.Fragment = ""
+		p = external.String()
 	}
-	return false
+
+	// Normalize the path for duplicate detection to handle trailing slashes
+	p = normalizeURLPath(p)
+	if query := s.query.normalize(url.RawQuery); query != "" {
+		p += "?" + query
+	}
+	return p
+}
+
+// markProcessed adds the normalized path to the processed set and returns
+// whether it was not contained in the set before.
+func (s *Scraper) markProcessed(normalizedPath string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.processed.Contains(normalizedPath) {
+		return false
+	}
+	s.processed.Add(normalizedPath)
+	return true
 }
 
-func (s *Scraper) isURLExcluded(url *url.URL) bool {
-	for _, re := range s.excludes {
-		if re.MatchString(url.Path) {
-			s.logger.Info("Skipping URL",
-				log.String("url", url.String()),
-				log.Stringer("excluded_expression", re))
-			return true
+// Explain returns a description of the decisions whether the URL would be
+// downloaded as page linked from the start page and as asset, without
+// downloading anything. The robots.txt rules are not checked.
+func (s *Scraper) Explain(u *url.URL) []string {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return []string{"page and asset: excluded, only http and https URLs are downloaded"}
+	}
+
+	var lines []string
+	page := ruleTarget{url: u, depth: 1}
+	if _, ok := s.domains.hostSpan(s.URL.Host, u.Host, 0); !ok {
+		lines = append(lines, "page: excluded, the host "+u.Host+" is not a page domain")
+	} else {
+		lines = append(lines, "page: "+explainDecision(s.pageRules.decide(page)))
+	}
+
+	if !s.domains.isAssetHostAllowed(s.URL.Host, u.Host) {
+		lines = append(lines, "asset: excluded, the host "+u.Host+" is not an asset domain")
+	} else {
+		lines = append(lines, "asset: "+explainDecision(s.assetRules.decide(ruleTarget{url: u})))
+	}
+	return lines
+}
+
+// explainDecision returns a description of a rule decision.
+func explainDecision(decision ruleDecision) string {
+	switch {
+	case !decision.final:
+		return "decided after the download by rule '" + decision.rule.text + "' and the following rules"
+	case decision.rule == nil && decision.included:
+		return "included, no rule matched"
+	case decision.rule == nil:
+		return "excluded, no include rule matched"
+	case decision.included:
+		return "included by rule '" + decision.rule.text + "'"
+	default:
+		return "excluded by rule '" + decision.rule.text + "'"
+	}
+}
+
+// isURLAllowedByRules checks the URL against the page or asset rules. If a
+// rule depends on the response, the URL is allowed and gets checked again
+// after the download by isResponseAllowedByRules.
+func (s *Scraper) isURLAllowedByRules(target ruleTarget, isAsset bool) bool {
+	rules := s.pageRules
+	if isAsset {
+		rules = s.assetRules
+	}
+
+	decision := rules.decide(target)
+	if !decision.final {
+		s.mu.Lock()
+		s.pendingRules[target.url.String()] = target
+		s.mu.Unlock()
+		return true
+	}
+
+	s.logRuleDecision(target.url, decision)
+	return decision.included
+}
+
+// isResponseAllowedByRules checks a downloaded URL against the rules that
+// depend on the response like the content type or size. The body start is
+// used to detect the content type if the response has no Content-Type.
+func (s *Scraper) isResponseAllowedByRules(u *url.URL, contentType string, bodyStart []byte,
+	size int64, isAsset bool) bool {
+
+	s.mu.Lock()
+	target, ok := s.pendingRules[u.String()]
+	delete(s.pendingRules, u.String())
+	s.mu.Unlock()
+	if !ok {
+		return true // decided before the download
+	}
+
+	rules := s.pageRules
+	if isAsset {
+		rules = s.assetRules
+	}
+
+	target.responseKnown = true
+	target.contentType = mediaType(contentType)
+	if target.contentType == "" {
+		target.contentType = mediaType(http.DetectContentType(bodyStart))
+	}
+	target.size = size
+
+	decision := rules.decide(target)
+	s.logRuleDecision(u, decision)
+	return decision.included
+}
+
+// logRuleDecision logs the decision of a rule that included or excluded
+// a URL.
+func (s *Scraper) logRuleDecision(u *url.URL, decision ruleDecision) {
+	switch {
+	case decision.rule == nil:
+		if !decision.included {
+			s.logger.Debug("Skipping URL not matching any include rule", log.String("url", u.String()))
+		}
+	case decision.included:
+		if decision.rule.include {
+			s.logger.Info("Including URL",
+				log.String("url", u.String()),
+				log.String("rule", decision.rule.text))
 		}
+	default:
+		s.logger.Info("Skipping URL",
+			log.String("url", u.String()),
+			log.String("rule", decision.rule.text))
 	}
-	return false
 }
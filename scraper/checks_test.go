@@ -1,6 +1,7 @@
 package scraper
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
@@ -44,6 +45,8 @@ func TestShouldURLBeDownloaded_TrailingSlashDuplicates(t *testing.T) {
 
 	// Initialize empty processed set
 	scraper.processed = set.New[string]()
+	scraper.config.IgnoreRobotsTxt = true
+	ctx := context.Background()
 
 	// Test that URLs with and without trailing slashes are treated as duplicates
 	url1, err := url.Parse("https://example.com/category/blog-post")
@@ -53,11 +56,11 @@ func TestShouldURLBeDownloaded_TrailingSlashDuplicates(t *testing.T) {
 	require.NoError(t, err)
 
 	// First URL should be downloadable
-	should1 := scraper.shouldURLBeDownloaded(url1, 0, false)
+	should1 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url1}, false)
 	assert.True(t, should1, "First URL should be downloadable")
 
 	// Second URL with trailing slash should be treated as duplicate
-	should2 := scraper.shouldURLBeDownloaded(url2, 0, false)
+	should2 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url2}, false)
 	assert.False(t, should2, "Second URL with trailing slash should be treated as duplicate")
 
 	// Verify that the normalized path is in the processed set
@@ -75,6 +78,8 @@ func TestShouldURLBeDownloaded_TrailingSlashDuplicatesReverse(t *testing.T) {
 
 	// Initialize empty processed set
 	scraper.processed = set.New[string]()
+	scraper.config.IgnoreRobotsTxt = true
+	ctx := context.Background()
 
 	// Test reverse order - trailing slash first, then without
 	url1, err := url.Parse("https://example.com/category/blog-post/")
@@ -84,11 +89,11 @@ func TestShouldURLBeDownloaded_TrailingSlashDuplicatesReverse(t *testing.T) {
 	require.NoError(t, err)
 
 	// First URL with trailing slash should be downloadable
-	should1 := scraper.shouldURLBeDownloaded(url1, 0, false)
+	should1 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url1}, false)
 	assert.True(t, should1, "First URL with trailing slash should be downloadable")
 
 	// Second URL without trailing slash should be treated as duplicate
-	should2 := scraper.shouldURLBeDownloaded(url2, 0, false)
+	should2 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url2}, false)
 	assert.False(t, should2, "Second URL without trailing slash should be treated as duplicate")
 
 	// Verify that the normalized path is in the processed set
@@ -106,6 +111,8 @@ func TestShouldURLBeDownloaded_RootPath(t *testing.T) {
 
 	// Initialize empty processed set
 	scraper.processed = set.New[string]()
+	scraper.config.IgnoreRobotsTxt = true
+	ctx := context.Background()
 
 	// Test root path normalization
 	url1, err := url.Parse("https://example.com/")
@@ -115,11 +122,11 @@ func TestShouldURLBeDownloaded_RootPath(t *testing.T) {
 	require.NoError(t, err)
 
 	// First root URL should be downloadable
-	should1 := scraper.shouldURLBeDownloaded(url1, 0, false)
+	should1 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url1}, false)
 	assert.True(t, should1, "First root URL should be downloadable")
 
 	// Second root URL should be treated as duplicate
-	should2 := scraper.shouldURLBeDownloaded(url2, 0, false)
+	should2 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url2}, false)
 	assert.False(t, should2, "Second root URL should be treated as duplicate")
 
 	// Verify that the normalized root path is in the processed set
@@ -137,6 +144,8 @@ func TestShouldURLBeDownloaded_ExternalURLs(t *testing.T) {
 
 	// Initialize empty processed set
 	scraper.processed = set.New[string]()
+	scraper.config.IgnoreRobotsTxt = true
+	ctx := context.Background()
 
 	// Test external URLs with trailing slashes as assets
 	url1, err := url.Parse("https://external.com/path.css")
@@ -146,10 +155,10 @@ func TestShouldURLBeDownloaded_ExternalURLs(t *testing.T) {
 	require.NoError(t, err)
 
 	// First external asset should be downloadable (if it passes other checks)
-	should1 := scraper.shouldURLBeDownloaded(url1, 0, true) // asset = true
+	should1 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url1}, true) // asset = true
 
 	// Second external asset with trailing slash should be treated as duplicate
-	should2 := scraper.shouldURLBeDownloaded(url2, 0, true) // asset = true
+	should2 := scraper.shouldURLBeDownloaded(ctx, ruleTarget{url: url2}, true) // asset = true
 
 	// First should pass, second should be blocked as duplicate
 	assert.True(t, should1, "First external asset should be downloadable")
@@ -0,0 +1,101 @@
+package scraper
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/h2non/filetype"
+	"github.com/h2non/filetype/types"
+)
+
+// pageMediaTypes contains the media types of documents that are parsed as
+// HTML pages to look for links.
+var pageMediaTypes = map[string]struct{}{
+	"text/html":             {},
+	"application/xhtml+xml": {},
+}
+
+// genericMediaTypes contains media types that do not describe the content,
+// the content type is detected by sniffing the data for these.
+var genericMediaTypes = map[string]struct{}{
+	"":                         {},
+	"application/octet-stream": {},
+	"binary/octet-stream":      {},
+	"application/unknown":      {},
+}
+
+// mediaTypeExtensions contains the file extensions of common media types
+// that are used instead of the system mime database to get stable results.
+var mediaTypeExtensions = map[string]string{
+	"application/javascript": ".js",
+	"application/json":       ".json",
+	"application/ld+json":    ".json",
+	"application/pdf":        ".pdf",
+	"application/rss+xml":    ".xml",
+	"application/atom+xml":   ".xml",
+	"application/xml":        ".xml",
+	"application/zip":        ".zip",
+	"image/gif":              ".gif",
+	"image/jpeg":             ".jpg",
+	"image/png":              ".png",
+	"image/svg+xml":          ".svg",
+	"image/webp":             ".webp",
+	"text/css":               ".css",
+	"text/csv":               ".csv",
+	"text/javascript":        ".js",
+	"text/plain":             ".txt",
+	"text/xml":               ".xml",
+}
+
+// contentInfo describes the content of a downloaded document.
+type contentInfo struct {
+	isPage    bool   // HTML page that gets parsed for links
+	extension string // file extension for documents that are no pages, empty if unknown
+	charset   string // charset of the Content-Type header, empty if not set
+}
+
+// detectContent detects the content of a downloaded document based on the
+// Content-Type header of the response. Sniffing the data is only used as a
+// fallback if the header is missing or generic, unknown data is handled as
+// a page.
+func detectContent(contentType string, data []byte) contentInfo {
+	var info contentInfo
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	info.charset = strings.ToLower(params["charset"])
+
+	if _, ok := pageMediaTypes[mediaType]; ok {
+		info.isPage = true
+		return info
+	}
+
+	if _, ok := genericMediaTypes[mediaType]; !ok {
+		info.extension = extensionForMediaType(mediaType)
+		return info
+	}
+
+	kind, err := filetype.Match(data)
+	if err != nil || kind == types.Unknown {
+		info.isPage = true
+		return info
+	}
+	info.extension = "." + kind.Extension
+	return info
+}
+
+// extensionForMediaType returns the file extension for a media type or an
+// empty string if it is unknown.
+func extensionForMediaType(mediaType string) string {
+	if ext, ok := mediaTypeExtensions[mediaType]; ok {
+		return ext
+	}
+
+	extensions, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(extensions) == 0 {
+		return ""
+	}
+	return extensions[0]
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectContent(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a}
+
+	tests := []struct {
+		contentType string
+		data        []byte
+		expected    contentInfo
+	}{
+		{"text/html", nil, contentInfo{isPage: true}},
+		{"text/html; charset=ISO-8859-1", nil, contentInfo{isPage: true, charset: "iso-8859-1"}},
+		{"application/xhtml+xml", nil, contentInfo{isPage: true}},
+		{"application/json", []byte(`{}`), contentInfo{extension: ".json"}},
+		{"application/rss+xml; charset=utf-8", nil, contentInfo{extension: ".xml", charset: "utf-8"}},
+		{"text/plain", []byte("<html>"), contentInfo{extension: ".txt"}},
+		{"text/html", png, contentInfo{isPage: true}},
+		{"", png, contentInfo{extension: ".png"}},
+		{"application/octet-stream", png, contentInfo{extension: ".png"}},
+		{"", []byte("<html></html>"), contentInfo{isPage: true}},
+		{"invalid/", []byte("<html></html>"), contentInfo{isPage: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			assert.Equal(t, test.expected, detectContent(test.contentType, test.data))
+		})
+	}
+}
+
+func TestScraperContentType(t *testing.T) {
+	fullURL := "https://example.org"
+	responses := map[string]*httpResponse{
+		"/": {
+			data:        []byte(`<html><body><a href="/data">data</a><a href="/feed.rss">feed</a><a href="/notes/">notes</a></body></html>`),
+			contentType: "text/html; charset=utf-8",
+		},
+		"/data": {
+			data:        []byte(`{"html": "<a href=\"/hidden\">hidden</a>"}`),
+			contentType: "application/json",
+		},
+		"/feed.rss": {
+			data:        []byte(`<rss><channel><link>https://example.org/hidden</link></channel></rss>`),
+			contentType: "application/rss+xml",
+		},
+		"/notes/": {
+			data:        []byte(`<html><a href="/hidden">hidden</a></html>`),
+			contentType: "text/plain",
+		},
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             fullURL + "/",
+		Storage:         files,
+		IgnoreRobotsTxt: true,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, nil)
+	downloaded := set.New[string]()
+	scraper.httpDownloader = func(_ context.Context, u *url.URL, _ *streamTarget) (*httpResponse, error) {
+		downloaded.Add(u.Path)
+		resp, ok := responses[u.Path]
+		require.True(t, ok, u.String())
+		return &httpResponse{data: resp.data, url: u, contentType: resp.contentType}, nil
+	}
+
+	require.NoError(t, scraper.Start(context.Background()))
+	assert.Equal(t, set.NewFromSlice([]string{"/", "/data", "/feed.rss", "/notes/"}), downloaded)
+
+	// the links of the index page point to the page files, which forward to
+	// the documents that are stored with the extension of their Content-Type
+	expected := []string{
+		"example.org/data.html",
+		"example.org/data.json",
+		"example.org/feed.rss",
+		"example.org/index.html",
+		"example.org/notes/index.html",
+		"example.org/notes/index.txt",
+	}
+	assert.Equal(t, expected, files.Paths())
+
+	data, err := files.ReadFile("example.org/data.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(responses["/data"].data), string(data))
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="data.html">data</a>`)
+	assert.Contains(t, string(index), `<a href="notes/index.html">notes</a>`)
+
+	stub, err := files.ReadFile("example.org/data.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(stub), `<meta http-equiv="refresh" content="0; url=data.json">`)
+	stub, err = files.ReadFile("example.org/notes/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(stub), `<meta http-equiv="refresh" content="0; url=index.txt">`)
+}
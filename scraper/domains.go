@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"net"
+	"strings"
+)
+
+// domainPolicy decides from which hosts pages and assets are downloaded.
+// Domain patterns like example.com also match the www or apex equivalent of
+// the domain, a pattern like *.example.com matches all of its subdomains.
+type domainPolicy struct {
+	pageDomains          []string // domains of pages to download besides the main host
+	assetDomains         []string // domains of assets to download, all if empty
+	excludedAssetDomains []string // domains of assets to not download
+	spanHostsDepth       uint     // number of links to follow to pages of other hosts
+}
+
+func newDomainPolicy(cfg Config) domainPolicy {
+	return domainPolicy{
+		pageDomains:          normalizeDomains(cfg.PageDomains),
+		assetDomains:         normalizeDomains(cfg.AssetDomains),
+		excludedAssetDomains: normalizeDomains(cfg.ExcludedAssetDomains),
+		spanHostsDepth:       cfg.SpanHostsDepth,
+	}
+}
+
+// isPageHost returns whether pages of the host are downloaded independent
+// of the number of links that lead to the page from other hosts.
+func (p domainPolicy) isPageHost(mainHost, host string) bool {
+	mainHost, host = strings.ToLower(mainHost), strings.ToLower(host)
+	if strings.TrimPrefix(host, "www.") == strings.TrimPrefix(mainHost, "www.") {
+		return true
+	}
+	return matchesAnyDomain(p.pageDomains, host)
+}
+
+// hostSpan returns the number of links that lead from a page of a page host
+// to a page of the given host and whether the page is downloaded. The parent
+// span is the span of the page that links to the host.
+func (p domainPolicy) hostSpan(mainHost, host string, parentSpan uint) (uint, bool) {
+	if p.isPageHost(mainHost, host) {
+		return 0, true
+	}
+	span := parentSpan + 1
+	return span, span <= p.spanHostsDepth
+}
+
+// isAssetHostAllowed returns whether assets of the host are downloaded.
+func (p domainPolicy) isAssetHostAllowed(mainHost, host string) bool {
+	if matchesAnyDomain(p.excludedAssetDomains, host) {
+		return false
+	}
+	if len(p.assetDomains) == 0 || p.isPageHost(mainHost, host) {
+		return true
+	}
+	return matchesAnyDomain(p.assetDomains, host)
+}
+
+// matchesAnyDomain returns whether the host matches any of the domain patterns.
+func matchesAnyDomain(patterns []string, host string) bool {
+	name := hostname(host)
+	for _, pattern := range patterns {
+		if matchesDomain(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDomain returns whether the host name matches the domain pattern.
+func matchesDomain(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(name, "."+suffix)
+	}
+	return strings.TrimPrefix(pattern, "www.") == strings.TrimPrefix(name, "www.")
+}
+
+// hostname returns the lowercase host name without the port.
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(host)
+}
+
+// normalizeDomains returns the lowercase domain patterns with a trailing dot
+// removed, empty patterns are skipped.
+func normalizeDomains(patterns []string) []string {
+	var domains []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if pattern != "" {
+			domains = append(domains, pattern)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,171 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainPolicy(t *testing.T) {
+	policy := newDomainPolicy(Config{
+		PageDomains:          []string{"*.example.org", "Docs.Example.com."},
+		AssetDomains:         []string{"cdn.example.net"},
+		ExcludedAssetDomains: []string{"ads.example.org"},
+		SpanHostsDepth:       1,
+	})
+	mainHost := "example.org"
+
+	pageHosts := map[string]bool{
+		"example.org":          true,
+		"www.example.org":      true,
+		"EXAMPLE.org":          true,
+		"blog.example.org":     true,
+		"docs.example.com":     true,
+		"www.docs.example.com": true,
+		"example.com":          false,
+		"other.org":            false,
+		"example.org:8080":     false,
+	}
+	for host, expected := range pageHosts {
+		assert.Equal(t, expected, policy.isPageHost(mainHost, host), host)
+	}
+
+	span, ok := policy.hostSpan(mainHost, "blog.example.org", 1)
+	assert.True(t, ok)
+	assert.Equal(t, uint(0), span)
+	span, ok = policy.hostSpan(mainHost, "other.org", 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), span)
+	_, ok = policy.hostSpan(mainHost, "another.org", span)
+	assert.False(t, ok)
+
+	assetHosts := map[string]bool{
+		"example.org":     true,
+		"img.example.org": true,
+		"cdn.example.net": true,
+		"ads.example.org": false,
+		"other.org":       false,
+	}
+	for host, expected := range assetHosts {
+		assert.Equal(t, expected, policy.isAssetHostAllowed(mainHost, host), host)
+	}
+
+	policy = newDomainPolicy(Config{ExcludedAssetDomains: []string{"*.ads.net"}})
+	assert.True(t, policy.isAssetHostAllowed(mainHost, "other.org"))
+	assert.False(t, policy.isAssetHostAllowed(mainHost, "track.ads.net"))
+	_, ok = policy.hostSpan(mainHost, "other.org", 0)
+	assert.False(t, ok)
+}
+
+func TestScraperDomainPolicy(t *testing.T) {
+	indexPage := []byte(`<html><body>
+<a href="https://blog.example.org/post">Post</a>
+<a href="https://other.org/">Other</a>
+<img src="https://ads.example.net/pixel.gif">
+</body></html>`)
+	postPage := []byte(`<html><body>
+<a href="https://example.org/about">About</a>
+<a href="/archive">Archive</a>
+<img src="/img/post.png">
+</body></html>`)
+	otherPage := []byte(`<html><body><a href="https://third.org/">Third</a></body></html>`)
+	empty := []byte(`<html></html>`)
+
+	urls := map[string][]byte{
+		"https://example.org/":                  indexPage,
+		"https://example.org/about":             empty,
+		"https://blog.example.org/post":         postPage,
+		"https://blog.example.org/archive":      empty,
+		"https://blog.example.org/img/post.png": empty,
+		"https://other.org/":                    otherPage,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:                  "https://example.org/",
+		Storage:              files,
+		PageDomains:          []string{"*.example.org"},
+		ExcludedAssetDomains: []string{"ads.example.net"},
+		SpanHostsDepth:       1,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/about",
+		"https://blog.example.org/post",
+		"https://blog.example.org/archive",
+		"https://blog.example.org/img/post.png",
+		"https://other.org",
+		"https://third.org",
+		"https://ads.example.net/pixel.gif",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="_blog.example.org/post.html">Post</a>`)
+	assert.Contains(t, string(index), `<a href="_other.org/index.html">Other</a>`)
+	assert.Contains(t, string(index), `<img src="https://ads.example.net/pixel.gif"/>`)
+
+	post, err := files.ReadFile("example.org/_blog.example.org/post.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(post), `<a href="../about.html">About</a>`)
+	assert.Contains(t, string(post), `<a href="../_blog.example.org/archive.html">Archive</a>`)
+	assert.Contains(t, string(post), `<img src="../_blog.example.org/img/post.png"/>`)
+
+	other, err := files.ReadFile("example.org/_other.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(other), `<a href="https://third.org/">Third</a>`)
+}
+
+func TestScraperDomainPolicyRedirect(t *testing.T) {
+	indexPage := []byte(`<html><body><a href="https://other.org/old">Other</a></body></html>`)
+	newPage := []byte(`<html><body><a href="https://third.org/">Third</a></body></html>`)
+	empty := []byte(`<html></html>`)
+
+	urls := map[string][]byte{
+		"https://example.org/":    indexPage,
+		"https://example.org/new": newPage,
+		"https://third.org/":      empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:            "https://example.org/",
+		Storage:        files,
+		SpanHostsDepth: 1,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	download := scraper.httpDownloader
+	scraper.httpDownloader = func(ctx context.Context, u *url.URL, stream *streamTarget) (*httpResponse, error) {
+		if u.String() != "https://other.org/old" {
+			return download(ctx, u, stream)
+		}
+		final, err := url.Parse("https://example.org/new")
+		require.NoError(t, err)
+		return &httpResponse{data: newPage, url: final}, nil
+	}
+	require.NoError(t, scraper.Start(context.Background()))
+
+	// the page of the other host redirects back to the main host, the links
+	// of the final page are followed based on the host span of the main host
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/new",
+		"https://other.org/old",
+		"https://third.org",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	page, err := files.ReadFile("example.org/new.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<a href="_third.org/index.html">Third</a>`)
+	assert.True(t, files.Exists("example.org/_third.org/index.html"))
+}
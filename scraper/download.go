@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mime"
 	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/cornelk/goscrape/css"
 	"github.com/cornelk/goscrape/htmlindex"
 	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
 )
 
 // assetProcessor is a processor of a downloaded asset that can transform
@@ -17,27 +21,69 @@ import (
 type assetProcessor func(URL *url.URL, data []byte) []byte
 
 var tagsWithReferences = []string{
-	htmlindex.LinkTag,
 	htmlindex.ScriptTag,
 	htmlindex.BodyTag,
 	htmlindex.StyleTag,
+	htmlindex.AudioTag,
+	htmlindex.EmbedTag,
+	htmlindex.ObjectTag,
+	htmlindex.SourceTag,
+	htmlindex.TrackTag,
+	htmlindex.UseTag,
+	htmlindex.VideoTag,
 }
 
-func (s *Scraper) downloadReferences(ctx context.Context, index *htmlindex.Index) error {
-	references, err := index.URLs(htmlindex.BodyTag)
-	if err != nil {
-		s.logger.Error("Getting body node URLs failed", log.Err(err))
-	}
-	s.imagesQueue = append(s.imagesQueue, references...)
+// imageTags contains the tags whose references are downloaded as images.
+var imageTags = []string{
+	htmlindex.BodyTag,
+	htmlindex.FeImageTag,
+	htmlindex.ImageTag,
+	htmlindex.ImgTag,
+	htmlindex.InlineStyle,
+	htmlindex.InputTag,
+}
 
-	references, err = index.URLs(htmlindex.ImgTag)
-	if err != nil {
-		s.logger.Error("Getting img node URLs failed", log.Err(err))
+// fontExtensions contains the file extensions of fonts that are referenced
+// by stylesheets, they are downloaded without processing.
+var fontExtensions = set.NewFromSlice([]string{
+	".eot",
+	".otf",
+	".ttf",
+	".woff",
+	".woff2",
+})
+
+// assetReference is an asset to download together with the processor to
+// apply to its content.
+type assetReference struct {
+	url        *url.URL
+	source     string // tag of the element that referenced the asset
+	processor  assetProcessor
+	stylesheet bool // the references of the stylesheet are relinked by the processor
+}
+
+// downloadReferences downloads the assets that the page references.
+func (s *Scraper) downloadReferences(ctx context.Context, page *url.URL, index *htmlindex.Index) error {
+	for _, tag := range imageTags {
+		references, err := index.URLs(tag)
+		if err != nil {
+			s.logger.Error("Getting node URLs failed",
+				log.String("node", tag),
+				log.Err(err))
+		}
+		source := tag
+		if tag == htmlindex.InlineStyle {
+			source = htmlindex.StyleTag
+		}
+		for _, ur := range references {
+			s.report.addReferrer(ur, page)
+		}
+		s.queueImages(source, references...)
 	}
-	s.imagesQueue = append(s.imagesQueue, references...)
 
+	var assets []assetReference
 	for _, tag := range tagsWithReferences {
-		references, err = index.URLs(tag)
+		references, err := index.URLs(tag)
 		if err != nil {
 			s.logger.Error("Getting node URLs failed",
 				log.String("node", tag),
@@ -45,87 +91,254 @@ func (s *Scraper) downloadReferences(ctx context.Context, index *htmlindex.Index
 		}
 
 		var processor assetProcessor
-		if tag == htmlindex.LinkTag {
-			processor = s.cssProcessor
+		switch tag {
+		case htmlindex.SourceTag, htmlindex.VideoTag:
+			// picture sources and video posters are images, other
+			// media files are passed through unchanged by the recoder
+			processor = s.checkImageForRecode
 		}
 		for _, ur := range references {
-			if err := s.downloadAsset(ctx, ur, processor); err != nil && errors.Is(err, context.Canceled) {
-				return err
-			}
+			assets = append(assets, assetReference{url: ur, source: tag, processor: processor})
 		}
 	}
 
-	for _, image := range s.imagesQueue {
-		if err := s.downloadAsset(ctx, image, s.checkImageForRecode); err != nil && errors.Is(err, context.Canceled) {
+	// only stylesheets are processed as CSS, icons, manifests and preloaded
+	// files are stored unchanged
+	linkProcessors := []struct {
+		relation   string
+		processor  assetProcessor
+		stylesheet bool
+	}{
+		{htmlindex.LinkRelationStylesheet, s.cssProcessor, true},
+		{htmlindex.LinkRelationAsset, nil, false},
+	}
+	for _, link := range linkProcessors {
+		references, err := index.LinkURLs(link.relation)
+		if err != nil {
+			s.logger.Error("Getting link URLs failed",
+				log.String("relation", link.relation),
+				log.Err(err))
+		}
+		for _, ur := range references {
+			assets = append(assets, assetReference{
+				url:        ur,
+				source:     htmlindex.LinkTag,
+				processor:  link.processor,
+				stylesheet: link.stylesheet,
+			})
+		}
+	}
+
+	for _, asset := range assets {
+		s.report.addReferrer(asset.url, page)
+	}
+	if err := s.downloadAssets(ctx, assets); err != nil {
+		return err
+	}
+
+	// the queue contains the images and the imported stylesheets, fonts
+	// and images that stylesheets added, it is processed until it is empty
+	for assets = s.takeAssetQueue(); len(assets) > 0; assets = s.takeAssetQueue() {
+		if err := s.downloadAssets(ctx, assets); err != nil {
 			return err
 		}
 	}
-	s.imagesQueue = nil
 	return nil
 }
 
+// downloadAssets downloads the given assets using the worker pool.
+func (s *Scraper) downloadAssets(ctx context.Context, assets []assetReference) error {
+	return s.forEach(ctx, len(assets), func(i int) error {
+		asset := assets[i]
+		if err := s.downloadAsset(ctx, asset); err != nil && errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	})
+}
+
+// queueImages adds images to the queue of assets to download. The source is
+// the tag of the element that referenced the images.
+func (s *Scraper) queueImages(source string, images ...*url.URL) {
+	assets := make([]assetReference, 0, len(images))
+	for _, u := range images {
+		assets = append(assets, assetReference{url: u, source: source, processor: s.checkImageForRecode})
+	}
+	s.queueAssets(assets...)
+}
+
+// queueAssets adds assets to the queue of assets to download.
+func (s *Scraper) queueAssets(assets ...assetReference) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assetQueue = append(s.assetQueue, assets...)
+}
+
+// takeAssetQueue returns all queued assets and empties the queue.
+func (s *Scraper) takeAssetQueue() []assetReference {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assets := s.assetQueue
+	s.assetQueue = nil
+	return assets
+}
+
 // downloadAsset downloads an asset if it does not exist on disk yet.
-func (s *Scraper) downloadAsset(ctx context.Context, u *url.URL, processor assetProcessor) error {
+func (s *Scraper) downloadAsset(ctx context.Context, asset assetReference) error {
+	u, processor := asset.url, asset.processor
 	u.Fragment = ""
 	urlFull := u.String()
 
-	if !s.shouldURLBeDownloaded(u, 0, true) {
+	if !s.shouldURLBeDownloaded(ctx, ruleTarget{url: u, source: asset.source}, true) {
 		return nil
 	}
 
 	filePath := s.getFilePath(u, false)
-	if s.fileExists(filePath) {
+	if s.storage.Exists(filePath) && !s.canRevalidate(u) {
+		s.recordResult(u, nil, nil)
 		return nil
 	}
 
+	if err := s.reserveDownload(ctx, true); err != nil {
+		return err
+	}
+
 	s.logger.Info("Downloading asset", log.String("url", urlFull))
-	data, _, err := s.httpDownloader(ctx, u)
+	started := time.Now()
+	resp, err := s.download(ctx, u, s.assetStreamTarget(asset, filePath))
+	s.report.addDownload(u, ReportTypeAsset, resp, err, time.Since(started))
 	if err != nil {
-		s.logger.Error("Downloading asset failed",
-			log.String("url", urlFull),
-			log.Err(err))
+		if !errors.Is(err, context.Canceled) { // stopped crawls are logged once
+			s.logger.Error("Downloading asset failed",
+				log.String("url", urlFull),
+				log.Err(err))
+			s.recordResult(u, err, nil)
+		}
 		return fmt.Errorf("downloading asset: %w", err)
 	}
+	s.recordResult(u, nil, nil)
 
+	stylesheet := asset.stylesheet || isStylesheet(u, resp.contentType)
+	if resp.notModified {
+		s.logger.Debug("Asset not modified", log.String("url", urlFull))
+		if !stylesheet {
+			return nil
+		}
+		if data, ok := s.cachedPage(u); ok {
+			s.cssProcessor(u, data) // queues the referenced assets for revalidation
+		}
+		return nil
+	}
+
+	if !s.isResponseAllowedByRules(u, resp.contentType, resp.bodyStart(), resp.bodySize(), true) {
+		s.discardFile(resp)
+		return nil
+	}
+	if resp.file != nil {
+		if s.commitFile(u, resp) != "" {
+			s.metadata.setFilePath(u, filePath)
+		}
+		return nil
+	}
+
+	data := resp.data
+	if processor == nil && stylesheet {
+		processor = s.cssProcessor // for example imported by a style tag
+	}
 	if processor != nil {
 		data = processor(u, data)
 	}
+	if s.config.WARCOnly {
+		return nil
+	}
 
-	if err = s.fileWriter(filePath, data); err != nil {
+	if err = s.storage.WriteFile(filePath, data); err != nil {
 		s.logger.Error("Writing asset file failed",
 			log.String("url", urlFull),
 			log.String("file", filePath),
 			log.Err(err))
+		return nil
+	}
+	s.metadata.setFilePath(u, filePath)
+	if stylesheet {
+		s.storePageCache(u, resp.data)
 	}
 
 	return nil
 }
 
-func (s *Scraper) cssProcessor(baseURL *url.URL, data []byte) []byte {
-	urls := make(map[string]string)
-
-	processor := func(token *css.Token, data string, u *url.URL) {
-		s.imagesQueue = append(s.imagesQueue, u)
+// assetStreamTarget returns the stream target for the download of an asset.
+// Stylesheets and images that get reencoded are read into memory, all other
+// assets are streamed to the file path.
+func (s *Scraper) assetStreamTarget(asset assetReference, filePath string) *streamTarget {
+	if asset.stylesheet || (asset.processor != nil && s.config.ImageQuality > 0) {
+		return nil
+	}
 
-		cssPath := *u
-		cssPath.Path = path.Dir(cssPath.Path) + "/"
-		resolved := resolveURL(&cssPath, data, s.URL.Host, false, "")
-		urls[token.Value] = resolved
+	return &streamTarget{
+		filePath: func(_ *url.URL, contentType string) string {
+			if isStylesheet(asset.url, contentType) {
+				return ""
+			}
+			return filePath
+		},
+		resumePath: filePath,
 	}
+}
 
-	cssData := string(data)
-	css.Process(s.logger, baseURL, cssData, processor)
+// cssProcessor relinks the references of a stylesheet relative to its stored
+// location and queues the referenced assets. Imported stylesheets are queued
+// to be processed by the CSS pipeline as well, cycles of imports are stopped
+// by the processed check of the asset download.
+func (s *Scraper) cssProcessor(baseURL *url.URL, data []byte) []byte {
+	var changed bool
 
-	if len(urls) == 0 {
-		return data
+	relativeToRoot := urlRelativeToRoot(baseURL)
+	if baseURL.Host != s.URL.Host {
+		relativeToRoot = "../" + relativeToRoot // files of other hosts are stored in a subdirectory
 	}
 
-	for ori, filePath := range urls {
-		cssData = replaceCSSUrls(ori, filePath, cssData)
+	rewriter := func(ref css.Reference) (string, bool) {
+		s.report.addReferrer(ref.URL, baseURL)
+		switch {
+		case ref.Import:
+			s.queueAssets(assetReference{url: ref.URL, source: sourceCSS, processor: s.cssProcessor, stylesheet: true})
+		case fontExtensions.Contains(strings.ToLower(path.Ext(ref.URL.Path))):
+			s.queueAssets(assetReference{url: ref.URL, source: sourceCSS})
+		default:
+			s.queueImages(sourceCSS, ref.URL)
+		}
+
+		resolved := resolveURL(baseURL, ref.Value, s.mirror(baseURL), false, relativeToRoot)
+		if resolved == ref.Value {
+			return "", false
+		}
+
 		s.logger.Debug("CSS Element relinked",
-			log.String("url", ori),
-			log.String("fixed_url", filePath))
+			log.String("url", ref.Value),
+			log.String("fixed_url", resolved))
+		changed = true
+		return resolved, true
 	}
 
+	cssData := css.Rewrite(s.logger, baseURL, string(data), rewriter)
+	if !changed {
+		return data
+	}
 	return []byte(cssData)
 }
+
+// isStylesheet returns whether a downloaded asset is a stylesheet based on
+// the Content-Type of the response or the file extension as fallback.
+func isStylesheet(u *url.URL, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "text/css" {
+		return true
+	}
+	if _, ok := genericMediaTypes[mediaType]; !ok && err == nil {
+		return false
+	}
+	return strings.EqualFold(path.Ext(u.Path), ".css")
+}
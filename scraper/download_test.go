@@ -1,10 +1,13 @@
 package scraper
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
+	"github.com/cornelk/goscrape/storage"
 	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,17 +33,132 @@ func TestCSSProcessor(t *testing.T) {
 
 	u, _ := url.Parse("http://localhost")
 	for input, expected := range fixtures {
-		s.imagesQueue = nil
 		s.cssProcessor(u, []byte(input))
+		assets := s.takeAssetQueue()
 
 		if expected == "" {
-			assert.Empty(t, s.imagesQueue)
+			assert.Empty(t, assets)
 			continue
 		}
 
-		assert.NotEmpty(t, s.imagesQueue)
+		require.NotEmpty(t, assets)
 
-		res := s.imagesQueue[0].String()
+		res := assets[0].url.String()
 		assert.Equal(t, expected, res)
 	}
 }
+
+func TestCSSProcessorImports(t *testing.T) {
+	logger := log.NewTestLogger(t)
+	cfg := Config{
+		URL: "https://example.org",
+	}
+	s, err := New(logger, cfg)
+	require.NoError(t, err)
+
+	input := `@import url("base.css") screen;
+@import 'theme/dark.css';
+@font-face { src: url(../fonts/icons.woff2?v=1#iefix) format("woff2"); }
+body { background: url( "../img/bg.png" ); font-family: 'Open Sans'; }`
+
+	u, err := url.Parse("https://example.org/css/style.css")
+	require.NoError(t, err)
+	output := s.cssProcessor(u, []byte(input))
+
+	expected := `@import url("base.css") screen;
+@import 'theme/dark.css';
+@font-face { src: url('../fonts/icons_v=1.woff2#iefix') format("woff2"); }
+body { background: url( "../img/bg.png" ); font-family: 'Open Sans'; }`
+	assert.Equal(t, expected, string(output))
+
+	queued := map[string]bool{}
+	for _, asset := range s.takeAssetQueue() {
+		queued[asset.url.String()] = asset.processor != nil
+	}
+	assert.Len(t, queued, 4)
+	assert.True(t, queued["https://example.org/css/base.css"])
+	assert.True(t, queued["https://example.org/css/theme/dark.css"])
+	assert.False(t, queued["https://example.org/fonts/icons.woff2?v=1#iefix"])
+	assert.True(t, queued["https://example.org/img/bg.png"])
+}
+
+func TestCSSProcessorOtherHosts(t *testing.T) {
+	logger := log.NewTestLogger(t)
+	cfg := Config{
+		URL: "https://example.org",
+	}
+	s, err := New(logger, cfg)
+	require.NoError(t, err)
+
+	// the files of other hosts are stored in subdirectories of the root
+	// directory that contains the files of the main host
+	tests := []struct {
+		stylesheet string
+		input      string
+		expected   string
+	}{
+		{"https://example.org/css/style.css", `a { background: url(https://cdn.com/img/a.png); }`,
+			`a { background: url('../_cdn.com/img/a.png'); }`},
+		{"https://cdn.com/css/style.css", `a { background: url(https://example.org/img/a.png); }`,
+			`a { background: url('../../img/a.png'); }`},
+		{"https://cdn.com/css/style.css", `a { background: url(https://cdn2.com/c.png); }`,
+			`a { background: url('../../_cdn2.com/c.png'); }`},
+		{"https://cdn.com/css/style.css", `a { background: url(../img/b.png); }`,
+			`a { background: url('../../_cdn.com/img/b.png'); }`},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.stylesheet)
+		require.NoError(t, err)
+		output := s.cssProcessor(u, []byte(test.input))
+		assert.Equal(t, test.expected, string(output), test.input)
+	}
+}
+
+func TestScraperCSSImports(t *testing.T) {
+	indexPage := []byte(`<html><head><link href="/css/style.css" rel="stylesheet"></head>
+<style>@import "/css/print.css";</style></html>`)
+	style := []byte(`@import 'theme/colors.css'; body { background: url(/img/bg.png); }`)
+	colors := []byte(`@import url(../style.css); @font-face { src: url(/fonts/icon.woff2); }`)
+	print := []byte(`body { background: url(../img/print.png); }`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                     indexPage,
+		fullURL + "/css/style.css":        style,
+		fullURL + "/css/theme/colors.css": colors,
+		fullURL + "/css/print.css":        print,
+		fullURL + "/img/bg.png":           empty,
+		fullURL + "/img/print.png":        empty,
+		fullURL + "/fonts/icon.woff2":     empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             fullURL + "/",
+		Storage:         files,
+		IgnoreRobotsTxt: true,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/css/style.css",
+		"/css/theme/colors.css",
+		"/css/print.css",
+		"/img/bg.png",
+		"/img/print.png",
+		"/fonts/icon.woff2",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	data, err := files.ReadFile("example.org/css/theme/colors.css")
+	require.NoError(t, err)
+	assert.Equal(t, `@import url(../style.css); @font-face { src: url('../../fonts/icon.woff2'); }`, string(data))
+
+	data, err = files.ReadFile("example.org/css/style.css")
+	require.NoError(t, err)
+	assert.Equal(t, `@import 'theme/colors.css'; body { background: url('../img/bg.png'); }`, string(data))
+}
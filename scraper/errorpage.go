@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cornelk/goscrape/htmlindex"
+	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
+	"golang.org/x/net/html"
+)
+
+// ErrorPageName is the file name of the error page in the host directory,
+// it is served for missing files.
+const ErrorPageName = "404.html"
+
+// defaultAcceptedStatusCodes contains the response status codes besides 200
+// whose body is processed if none are configured.
+var defaultAcceptedStatusCodes = []int{http.StatusNonAuthoritativeInfo}
+
+// statusCodeError is returned for a response with a status code that is not
+// accepted.
+type statusCodeError struct {
+	code int
+	// page is the response of an error page that can be stored as error page
+	// of the website, nil if the body was not read
+	page *httpResponse
+}
+
+func (e *statusCodeError) Error() string {
+	return fmt.Sprintf("unexpected HTTP request status code %d", e.code)
+}
+
+func newAcceptedStatusCodes(cfg Config) set.Set[int] {
+	codes := cfg.AcceptedStatusCodes
+	if codes == nil {
+		codes = defaultAcceptedStatusCodes
+	}
+	accepted := set.NewFromSlice(codes)
+	accepted.Add(http.StatusOK)
+	return accepted
+}
+
+// unacceptedStatusError returns the error for a response with a status code
+// that is not accepted. The body of a missing page of the main host is read
+// if the error page of the website has not been stored yet.
+func (s *Scraper) unacceptedStatusError(u *url.URL, resp *http.Response, requestTime time.Time) error {
+	err := &statusCodeError{code: resp.StatusCode}
+
+	isMissing := resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone
+	if !s.config.ErrorPage || !isMissing || u.Host != s.URL.Host || s.errorPageStored.Load() {
+		s.archiveUnreadResponse(resp, requestTime)
+		return s.retry.statusError(err, resp)
+	}
+
+	page, readErr := s.readBody(u, resp, nil, nil)
+	if readErr != nil {
+		s.logger.Debug("Reading error page failed",
+			log.String("url", u.String()),
+			log.Err(readErr))
+		s.archiveResponse(resp, nil, requestTime)
+		return s.retry.statusError(err, resp)
+	}
+
+	s.archiveResponse(resp, page.data, requestTime)
+	err.page = page
+	return s.retry.statusError(err, resp)
+}
+
+// storeErrorPage stores the error page of a failed page download as error
+// page of the website, only the first error page is stored. Its references
+// are resolved against the URL that returned it and relinked as root-absolute
+// paths, as the page is served for missing files of all directories.
+func (s *Scraper) storeErrorPage(ctx context.Context, err error) {
+	var statusErr *statusCodeError
+	if !errors.As(err, &statusErr) || statusErr.page == nil {
+		return
+	}
+	page := statusErr.page
+	if !detectContent(page.contentType, page.data).isPage || !s.errorPageStored.CompareAndSwap(false, true) {
+		return
+	}
+
+	doc, err := html.Parse(bytes.NewReader(page.data))
+	if err != nil {
+		s.logger.Error("Parsing error page failed",
+			log.String("url", page.url.String()),
+			log.Err(err))
+		return
+	}
+
+	index := htmlindex.New(s.logger)
+	index.Index(page.url, doc)
+	s.fixHTMLNodeURLs(page.url, absoluteRoot, index)
+
+	var rendered bytes.Buffer
+	if err := html.Render(&rendered, doc); err != nil {
+		s.logger.Error("Rendering error page failed",
+			log.String("url", page.url.String()),
+			log.Err(err))
+		return
+	}
+
+	pageURL := &url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: "/" + ErrorPageName}
+	if filePath := s.storeDownload(pageURL, rendered.Bytes(), s.getFilePath(pageURL, true)); filePath != "" {
+		s.logger.Info("Error page stored",
+			log.String("url", page.url.String()),
+			log.String("file", filePath))
+	}
+
+	if err := s.downloadReferences(ctx, page.url, index); err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.Error("Downloading error page references failed",
+			log.String("url", page.url.String()),
+			log.Err(err))
+	}
+}
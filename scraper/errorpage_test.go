@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newErrorPageTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, err := fmt.Fprint(w, `<a href="/info">Info</a><a href="/missing/page">Missing</a><a href="/sub/gone">Gone</a>`)
+			assert.NoError(t, err)
+		case "/info":
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			_, err := fmt.Fprint(w, `info`)
+			assert.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, err := fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/error.css"></head>`+
+				`<body><img src="logo.png">Not found</body></html>`)
+			assert.NoError(t, err)
+		}
+	})
+	mux.HandleFunc("/missing/logo.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, err := fmt.Fprint(w, "\x89PNG\r\n\x1a\n")
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("/error.css", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		_, err := fmt.Fprint(w, `body { color: red; }`)
+		assert.NoError(t, err)
+	})
+	svr := httptest.NewServer(mux)
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestScraperErrorPage(t *testing.T) {
+	svr := newErrorPageTestServer(t)
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             svr.URL + "/",
+		IgnoreRobotsTxt: true,
+		ErrorPage:       true,
+		Storage:         files,
+	}
+	s, err := New(log.NewNop(), cfg) // the missing pages get logged as error
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+
+	data, err := files.ReadFile(filepath.Join(u.Host, "info.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "info", string(data))
+
+	// the pages are processed in sorted order, the first missing page is
+	// /missing/page whose references are resolved against its URL and linked
+	// absolute to the host directory
+	data, err = files.ReadFile(filepath.Join(u.Host, ErrorPageName))
+	require.NoError(t, err)
+	expected := `<html><head><link rel="stylesheet" href="/error.css"/></head>` +
+		`<body><img src="/missing/logo.png"/>Not found</body></html>`
+	assert.Equal(t, expected, string(data))
+	assert.False(t, files.Exists(filepath.Join(u.Host, "missing", "page.html")))
+
+	// the error page is served for missing files of nested directories and
+	// its references point to the stored files
+	hostFS, err := fs.Sub(files.FS(), u.Host)
+	require.NoError(t, err)
+	handler := newFileHandler(http.FS(hostFS))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sub/dir/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, expected, rec.Body.String())
+
+	for _, reference := range []string{"/error.css", "/missing/logo.png"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, reference, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, reference)
+	}
+}
+
+func TestScraperAcceptedStatusCodes(t *testing.T) {
+	svr := newErrorPageTestServer(t)
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:                 svr.URL + "/",
+		IgnoreRobotsTxt:     true,
+		AcceptedStatusCodes: []int{http.StatusNotFound},
+		Storage:             files,
+	}
+	s, err := New(log.NewNop(), cfg) // the page with status 203 gets logged as error
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+
+	assert.False(t, files.Exists(filepath.Join(u.Host, "info.html")))
+	assert.True(t, files.Exists(filepath.Join(u.Host, "missing", "page.html")))
+	assert.True(t, files.Exists(filepath.Join(u.Host, "sub", "gone.html")))
+	assert.False(t, files.Exists(filepath.Join(u.Host, ErrorPageName)))
+}
+
+func TestFileHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.html":            {Data: []byte("page")},
+		ErrorPageName:          {Data: []byte("root error")},
+		"sub/" + ErrorPageName: {Data: []byte("sub error")},
+	}
+	handler := newFileHandler(http.FS(fsys))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/page.html", http.StatusOK, "page"},
+		{"/missing", http.StatusNotFound, "root error"},
+		{"/sub/dir/missing", http.StatusNotFound, "sub error"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		body, err := io.ReadAll(rec.Result().Body)
+		require.NoError(t, err)
+		assert.Equal(t, test.status, rec.Code, test.path)
+		assert.Equal(t, test.body, string(body), test.path)
+	}
+}
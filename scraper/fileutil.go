@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -30,6 +31,9 @@ func (s *Scraper) getFilePath(url *url.URL, isAPage bool) string {
 	}
 	// If not a page, keep the original URL path for binary files
 
+	// URLs that only differ in the query like paginated pages need separate files
+	fileName = s.query.fileNameWithQuery(fileName, url.RawQuery)
+
 	var externalHost string
 	if url.Host != s.URL.Host {
 		externalHost = "_" + url.Host // _ is a prefix for external domains on the filesystem
@@ -52,6 +56,22 @@ func (s *Scraper) getFilePath(url *url.URL, isAPage bool) string {
 	return filepath.Join(s.config.OutputDirectory, s.URL.Host, externalHost, fileName)
 }
 
+// getFileFilePath returns the file path for a document that is not a page.
+// The extension is appended if the URL path has no file extension, URLs of
+// directories are stored as index file inside of the directory.
+func (s *Scraper) getFileFilePath(url *url.URL, extension string) string {
+	if extension == "" || path.Ext(url.Path) != "" {
+		return s.getFilePath(url, false)
+	}
+
+	if url.Path == "" || strings.HasSuffix(url.Path, "/") {
+		index := *url
+		index.Path = strings.TrimSuffix(url.Path, "/") + "/index" + extension
+		return s.getFilePath(&index, false)
+	}
+	return s.getFilePath(url, false) + extension
+}
+
 // getPageFilePath returns a filename for a URL that represents a web page.
 // This function adds .html extensions and handles directory indexing,
 // which is what we want for HTML content but NOT for binary files like images or PDFs.
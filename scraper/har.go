@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/cornelk/goscrape/har"
+	"github.com/cornelk/gotokit/log"
+)
+
+// newHARRecorder returns a recorder that captures all HTTP exchanges of the
+// transport.
+func newHARRecorder(transport http.RoundTripper) *har.Recorder {
+	version := "dev"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	return har.NewRecorder(transport, har.Creator{
+		Name:    "goscrape",
+		Version: version,
+	})
+}
+
+// writeHAR writes all recorded HTTP exchanges to the HAR file if it is
+// enabled and logs errors.
+func (s *Scraper) writeHAR() {
+	if s.har == nil {
+		return
+	}
+
+	file := s.har.File()
+	if err := file.WriteFile(s.config.HARFile); err != nil {
+		s.logger.Error("Writing HAR file failed",
+			log.String("file", s.config.HARFile),
+			log.Err(err))
+		return
+	}
+
+	s.logger.Info("HAR file written",
+		log.String("file", s.config.HARFile),
+		log.Int("entries", len(file.Log.Entries)))
+}
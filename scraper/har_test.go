@@ -0,0 +1,67 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cornelk/goscrape/har"
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperHAR(t *testing.T) {
+	pages := map[string]string{
+		"/":        `<html><body><a href="/old">old</a><img src="/img.png"/></body></html>`,
+		"/new":     `<html><body>new</body></html>`,
+		"/img.png": "image",
+	}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		content, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer svr.Close()
+
+	cfg := Config{
+		URL:             svr.URL + "/",
+		IgnoreRobotsTxt: true,
+		Username:        "user",
+		Password:        "secret",
+		HARFile:         filepath.Join(t.TempDir(), "traffic.har"),
+		Storage:         storage.NewMemory(),
+	}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	data, err := os.ReadFile(cfg.HARFile)
+	require.NoError(t, err)
+	var file har.File
+	require.NoError(t, json.Unmarshal(data, &file))
+
+	entries := map[string]har.Entry{}
+	for _, entry := range file.Log.Entries {
+		entries[entry.Request.URL] = entry
+		assert.Contains(t, entry.Request.Headers, har.NameValue{Name: "Authorization", Value: har.RedactedValue})
+	}
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, http.StatusFound, entries[svr.URL+"/old"].Response.Status)
+	assert.Equal(t, svr.URL+"/new", entries[svr.URL+"/old"].Response.RedirectURL)
+	assert.Equal(t, http.StatusOK, entries[svr.URL+"/new"].Response.Status)
+	assert.Equal(t, int64(len("image")), entries[svr.URL+"/img.png"].Response.Content.Size)
+}
@@ -28,6 +28,9 @@ func (s *Scraper) fixURLReferences(url *url.URL, doc *html.Node,
 	index *htmlindex.Index) ([]byte, bool, error) {
 
 	relativeToRoot := urlRelativeToRoot(url)
+	if url.Host != s.URL.Host {
+		relativeToRoot = "../" + relativeToRoot // files of other hosts are stored in a subdirectory
+	}
 	if !s.fixHTMLNodeURLs(url, relativeToRoot, index) {
 		return nil, false, nil
 	}
@@ -41,22 +44,49 @@ func (s *Scraper) fixURLReferences(url *url.URL, doc *html.Node,
 
 // fixHTMLNodeURLs processes all HTML nodes that contain URLs that need to be fixed
 // to link to downloaded files. It returns whether any URLS have been fixed.
+// References of documents with a base element are resolved against its URL,
+// the base element is removed as the fixed references are relative to the
+// stored file of the page.
 func (s *Scraper) fixHTMLNodeURLs(baseURL *url.URL, relativeToRoot string, index *htmlindex.Index) bool {
 	var changed bool
+	documentBase := index.BaseURL()
+
+	// a node is indexed once for every of its references, but all its
+	// attributes are fixed at once
+	fixedNodes := map[*html.Node]struct{}{}
 
 	for tag, nodeInfo := range htmlindex.Nodes {
-		isHyperlink := tag == htmlindex.ATag
+		isHyperlink := htmlindex.PageTags.Contains(tag)
 
 		urls := index.Nodes(tag)
 		for _, nodes := range urls {
 			for _, node := range nodes {
+				if _, ok := fixedNodes[node]; ok {
+					continue
+				}
+				fixedNodes[node] = struct{}{}
+
 				switch node.Data {
+				case htmlindex.BaseTag:
+					if node.Parent != nil {
+						node.Parent.RemoveChild(node)
+						changed = true
+					}
+				case htmlindex.MetaTag:
+					if s.fixMetaRefreshURL(baseURL, documentBase, node, relativeToRoot) {
+						changed = true
+					}
+				case htmlindex.LinkTag:
+					isPageLink := htmlindex.LinkRelation(node) == htmlindex.LinkRelationPage
+					if s.fixNodeURL(baseURL, documentBase, nodeInfo.Attributes, node, isPageLink, relativeToRoot) {
+						changed = true
+					}
 				case htmlindex.StyleTag:
-					if s.fixScriptNodeURL(baseURL, node, isHyperlink, relativeToRoot) {
+					if s.fixScriptNodeURL(baseURL, documentBase, node, isHyperlink, relativeToRoot) {
 						changed = true
 					}
 				default:
-					if s.fixNodeURL(baseURL, nodeInfo.Attributes, node, isHyperlink, relativeToRoot) {
+					if s.fixNodeURL(baseURL, documentBase, nodeInfo.Attributes, node, isHyperlink, relativeToRoot) {
 						changed = true
 					}
 				}
@@ -64,12 +94,26 @@ func (s *Scraper) fixHTMLNodeURLs(baseURL *url.URL, relativeToRoot string, index
 		}
 	}
 
+	styleNodes := map[*html.Node]struct{}{}
+	for _, nodes := range index.Nodes(htmlindex.InlineStyle) {
+		for _, node := range nodes {
+			if _, ok := styleNodes[node]; ok {
+				continue
+			}
+			styleNodes[node] = struct{}{}
+
+			if s.fixStyleAttributeURL(baseURL, documentBase, node, relativeToRoot) {
+				changed = true
+			}
+		}
+	}
+
 	return changed
 }
 
 // fixNodeURL fixes the URL references of a HTML node to point to a relative file name.
 // It returns whether any attribute value bas been adjusted.
-func (s *Scraper) fixNodeURL(baseURL *url.URL, attributes []string, node *html.Node,
+func (s *Scraper) fixNodeURL(baseURL, documentBase *url.URL, attributes []string, node *html.Node,
 	isHyperlink bool, relativeToRoot string) bool {
 
 	var changed bool
@@ -93,9 +137,11 @@ func (s *Scraper) fixNodeURL(baseURL *url.URL, attributes []string, node *html.N
 		var adjusted string
 
 		if htmlindex.SrcSetAttributes.Contains(attr.Key) {
-			adjusted = resolveSrcSetURLs(baseURL, value, s.URL.Host, isHyperlink, relativeToRoot)
+			reference := absoluteSrcSet(documentBase, value)
+			adjusted = resolveSrcSetURLs(baseURL, reference, s.mirror(baseURL), isHyperlink, relativeToRoot)
 		} else {
-			adjusted = resolveURL(baseURL, value, s.URL.Host, isHyperlink, relativeToRoot)
+			reference := absoluteReference(documentBase, value)
+			adjusted = resolveURL(baseURL, reference, s.mirror(baseURL), isHyperlink, relativeToRoot)
 		}
 
 		if adjusted == value { // check for no change
@@ -114,66 +160,156 @@ func (s *Scraper) fixNodeURL(baseURL *url.URL, attributes []string, node *html.N
 	return changed
 }
 
+// fixMetaRefreshURL fixes the URL of a HTML meta refresh node to point to a
+// relative file name. It returns whether the content value has been adjusted.
+func (s *Scraper) fixMetaRefreshURL(baseURL, documentBase *url.URL, node *html.Node,
+	relativeToRoot string) bool {
+
+	for i, attr := range node.Attr {
+		if attr.Key != htmlindex.ContentAttribute {
+			continue
+		}
+
+		delay, value, ok := htmlindex.ParseMetaRefresh(attr.Val)
+		if !ok {
+			return false
+		}
+
+		reference := absoluteReference(documentBase, value)
+		adjusted := resolveURL(baseURL, reference, s.mirror(baseURL), true, relativeToRoot)
+		if adjusted == value {
+			return false
+		}
+
+		s.logger.Debug("HTML node relinked",
+			log.String("value", value),
+			log.String("fixed_value", adjusted))
+
+		attribute := &node.Attr[i]
+		attribute.Val = delay + "; url=" + adjusted
+		return true
+	}
+
+	return false
+}
+
 // fixScriptNodeURL fixes the URL references of a HTML script node to point to a relative file name.
 // It returns whether any attribute value bas been adjusted.
-func (s *Scraper) fixScriptNodeURL(baseURL *url.URL, node *html.Node,
+func (s *Scraper) fixScriptNodeURL(baseURL, documentBase *url.URL, node *html.Node,
 	isHyperlink bool, relativeToRoot string) bool {
 
 	if node.FirstChild == nil {
 		return false
 	}
 
-	urls := map[string]string{}
+	var changed bool
+	node.FirstChild.Data, changed = s.fixCSSURLs(baseURL, documentBase, node.FirstChild.Data,
+		isHyperlink, relativeToRoot)
+	return changed
+}
+
+// fixStyleAttributeURL fixes the URL references of the style attribute of a
+// HTML node to point to a relative file name.
+// It returns whether the attribute value bas been adjusted.
+func (s *Scraper) fixStyleAttributeURL(baseURL, documentBase *url.URL, node *html.Node,
+	relativeToRoot string) bool {
+
+	var changed bool
+
+	for i, attr := range node.Attr {
+		if attr.Key != htmlindex.StyleAttribute || attr.Namespace != "" {
+			continue
+		}
 
-	processor := func(_ *css.Token, before string, _ *url.URL) {
-		adjusted := resolveURL(baseURL, before, s.URL.Host, isHyperlink, relativeToRoot)
-		if before != adjusted {
-			urls[before] = adjusted
+		adjusted, attrChanged := s.fixCSSURLs(baseURL, documentBase, attr.Val, false, relativeToRoot)
+		if !attrChanged {
+			continue
 		}
+
+		attribute := &node.Attr[i]
+		attribute.Val = adjusted
+		changed = true
 	}
 
-	cssData := node.FirstChild.Data
-	css.Process(s.logger, baseURL, cssData, processor)
+	return changed
+}
+
+// fixCSSURLs fixes the URL references of CSS data to point to relative file
+// names. It returns the adjusted data and whether any reference was adjusted.
+func (s *Scraper) fixCSSURLs(baseURL, documentBase *url.URL, data string, isHyperlink bool,
+	relativeToRoot string) (string, bool) {
 
 	var changed bool
 
-	for before, filePath := range urls {
-		cssData = replaceCSSUrls(before, filePath, cssData)
+	rewriter := func(ref css.Reference) (string, bool) {
+		reference := ref.Value
+		if documentBase != nil {
+			reference = ref.URL.String()
+		}
+
+		adjusted := resolveURL(baseURL, reference, s.mirror(baseURL), isHyperlink, relativeToRoot)
+		if adjusted == ref.Value {
+			return "", false
+		}
+
 		s.logger.Debug("CSS Element relinked",
-			log.String("url", before),
-			log.String("fixed_url", filePath))
+			log.String("url", ref.Value),
+			log.String("fixed_url", adjusted))
 		changed = true
+		return adjusted, true
 	}
 
-	node.FirstChild.Data = cssData
+	cssBase := baseURL
+	if documentBase != nil {
+		cssBase = documentBase
+	}
+	data = css.Rewrite(s.logger, cssBase, data, rewriter)
+	return data, changed
+}
 
-	return changed
+// absoluteReference resolves the reference against the URL of the base
+// element of the document. The reference is returned unchanged if the
+// document has no base element.
+func absoluteReference(documentBase *url.URL, reference string) string {
+	if documentBase == nil {
+		return reference
+	}
+
+	u, err := documentBase.Parse(reference)
+	if err != nil {
+		return reference
+	}
+	return u.String()
 }
 
-func resolveSrcSetURLs(base *url.URL, srcSetValue, mainPageHost string, isHyperlink bool, relativeToRoot string) string {
-	// split the set of responsive images
-	values := strings.Split(srcSetValue, ",")
+// absoluteSrcSet resolves all URLs of a srcset value against the URL of the
+// base element of the document.
+func absoluteSrcSet(documentBase *url.URL, srcSetValue string) string {
+	if documentBase == nil {
+		return srcSetValue
+	}
 
+	values := strings.Split(srcSetValue, ",")
 	for i, value := range values {
-		value = strings.TrimSpace(value)
-		parts := strings.Split(value, " ")
-		parts[0] = resolveURL(base, parts[0], mainPageHost, isHyperlink, relativeToRoot)
+		parts := strings.Split(strings.TrimSpace(value), " ")
+		parts[0] = absoluteReference(documentBase, parts[0])
 		values[i] = strings.Join(parts, " ")
 	}
-
 	return strings.Join(values, ", ")
 }
 
-func replaceCSSUrls(before, after, content string) string {
-	prefixes := []string{
-		"\"", "'", "",
-	}
+func resolveSrcSetURLs(base *url.URL, srcSetValue string, m mirror,
+	isHyperlink bool, relativeToRoot string) string {
 
-	for _, prefix := range prefixes {
-		wrong := fmt.Sprintf("url(%s%s%s)", prefix, before, prefix)
-		fixed := "url('" + after + "')"
-		content = strings.ReplaceAll(content, wrong, fixed)
+	// split the set of responsive images
+	values := strings.Split(srcSetValue, ",")
+
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		parts := strings.Split(value, " ")
+		parts[0] = resolveURL(base, parts[0], m, isHyperlink, relativeToRoot)
+		values[i] = strings.Join(parts, " ")
 	}
 
-	return content
+	return strings.Join(values, ", ")
 }
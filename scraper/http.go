@@ -1,28 +1,24 @@
 package scraper
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/cornelk/goscrape/storage"
 	"github.com/cornelk/gotokit/app"
 	"github.com/cornelk/gotokit/log"
 )
 
-var (
-	maxRetries = 10
-	retryDelay = 1500 * time.Millisecond
-
-	errExhaustedRetries = errors.New("exhausted retries")
-)
-
-func (s *Scraper) downloadURL(ctx context.Context, u *url.URL) (*http.Response, error) {
+// downloadURL sends the request for the URL, a partial download is resumed
+// using a range request.
+func (s *Scraper) downloadURL(ctx context.Context, u *url.URL, partial *partialDownload) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating HTTP request: %w", err)
@@ -38,6 +34,11 @@ func (s *Scraper) downloadURL(ctx context.Context, u *url.URL) (*http.Response,
 			req.Header.Set(key, value)
 		}
 	}
+	if partial != nil {
+		partial.setHeaders(req.Header)
+	} else {
+		s.conditionalHeaders(u, req.Header)
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -47,52 +48,170 @@ func (s *Scraper) downloadURL(ctx context.Context, u *url.URL) (*http.Response,
 	return resp, nil
 }
 
-func (s *Scraper) downloadURLWithRetries(ctx context.Context, u *url.URL) ([]byte, *url.URL, error) {
-	var err error
-	var resp *http.Response
+// httpResponse contains the result of a completed HTTP request.
+type httpResponse struct {
+	data        []byte
+	url         *url.URL // final URL of the response after following redirects
+	contentType string   // value of the Content-Type header including parameters
+	statusCode  int
+	redirects   []string // URLs of the followed redirects, ending with the final URL
+
+	// file is set instead of data if the body was streamed to the file
+	// path, the caller has to commit or discard it
+	file     storage.File
+	filePath string
+	head     []byte   // first bytes of a streamed body
+	size     int64    // size of a streamed body
+	digest   string   // hex encoded SHA-256 digest of the body
+	spool    *os.File // temporary copy of a streamed body for the WARC output
+
+	// notModified is set if the server confirmed that the local copy from a
+	// previous scrape is unchanged, data is empty in this case.
+	notModified bool
+}
 
-	for retries := range maxRetries + 2 {
-		if retries == maxRetries+1 {
-			return nil, nil, fmt.Errorf("%w for URL %s", errExhaustedRetries, u)
+// downloadURLWithRetries downloads the URL and retries failed requests based
+// on the retry policy. The stream target decides whether the body is streamed
+// to a file, a nil stream target reads every body into memory. The partial
+// file of an interrupted download of the stream target is resumed. The
+// download slots are only held during an attempt and not while sleeping
+// between retries.
+func (s *Scraper) downloadURLWithRetries(ctx context.Context, u *url.URL, stream *streamTarget) (*httpResponse, error) {
+	resume := true
+	for attempt := uint(1); ; attempt++ {
+		// a failed attempt can leave a partial file that the retry resumes
+		var partial *partialDownload
+		if resume {
+			partial = s.resumablePartial(u, stream)
 		}
 
-		resp, err = s.downloadURL(ctx, u)
+		release, err := s.acquireDownloadSlot(ctx, u.Host)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
+		}
+		result, err := s.downloadAttempt(ctx, u, stream, partial)
+		release()
+		if err == nil {
+			return result, nil
 		}
 
-		if resp.StatusCode == http.StatusTooManyRequests {
-			s.logger.Warn("Too Many Requests. Retrying again",
-				log.Int("num", retries+1),
-				log.Int("max", maxRetries),
+		if errors.Is(err, errRangeNotSatisfiable) {
+			s.logger.Debug("Partial download can not be resumed, downloading it again",
 				log.String("url", u.String()))
+			resume = false
+			attempt-- // the complete download is not a retry
+			continue
+		}
 
-			// Wait a bit and try again using exponential backoff on each retry
-			if err := app.Sleep(ctx, (time.Duration(retries)+1)*retryDelay); err != nil {
-				return nil, nil, fmt.Errorf("sleeping between retries: %w", err)
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) {
+			return nil, err
+		}
+		if attempt >= s.retry.maxAttempts {
+			if attempt == 1 {
+				return nil, err
 			}
-			continue
+			return nil, fmt.Errorf("%w for URL %s: %w", errExhaustedRetries, u, err)
+		}
+
+		delay := s.retry.backoff(attempt, retryErr.retryAfter)
+		s.logger.Warn("Request failed. Retrying again",
+			log.Uint("num", attempt),
+			log.Uint("max", s.retry.maxAttempts-1),
+			log.Duration("delay", delay),
+			log.String("url", u.String()),
+			log.Err(err))
+
+		if err := app.Sleep(ctx, delay); err != nil {
+			return nil, fmt.Errorf("sleeping between retries: %w", err)
 		}
-		break
 	}
+}
+
+// downloadAttempt sends a single request for the URL and reads the body of
+// the response. Errors that can be retried are returned as retryableError,
+// errRangeNotSatisfiable is returned if the partial file can not be resumed.
+func (s *Scraper) downloadAttempt(ctx context.Context, u *url.URL, stream *streamTarget,
+	partial *partialDownload) (*httpResponse, error) {
 
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			s.logger.Error("Closing HTTP Request body failed",
-				log.String("url", u.String()),
-				log.Err(err))
+	requestTime := time.Now()
+	resp, err := s.downloadURL(ctx, u, partial)
+	if err != nil {
+		return nil, s.retry.networkError(ctx, err)
+	}
+	defer s.closeResponseBody(u, resp)
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && partial != nil {
+		s.archiveUnreadResponse(resp, requestTime)
+		return nil, errRangeNotSatisfiable
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		s.archiveUnreadResponse(resp, requestTime)
+		entry, _ := s.metadata.get(u)
+		return &httpResponse{
+			url:         resp.Request.URL,
+			contentType: entry.ContentType, // a 304 response does not need to repeat it
+			statusCode:  resp.StatusCode,
+			redirects:   redirectChain(resp),
+			notModified: true,
+		}, nil
+	}
+
+	if resp.StatusCode == http.StatusPartialContent && partial != nil {
+		s.logger.Info("Resuming partial download",
+			log.String("url", u.String()),
+			log.Int64("offset", partial.size))
+	} else {
+		partial = nil // the server sent the complete file
+		if !s.acceptedStatus.Contains(resp.StatusCode) {
+			return nil, s.unacceptedStatusError(u, resp, requestTime)
+		}
+	}
+
+	result, err := s.readBody(u, resp, stream, partial)
+	if err != nil {
+		if errors.Is(err, errResponseTooLarge) || errors.Is(err, errUnexpectedRange) {
+			s.archiveUnreadResponse(resp, requestTime)
+			return nil, err
 		}
-	}()
+		return nil, s.retry.networkError(ctx, err)
+	}
+	result.statusCode = resp.StatusCode
+	result.redirects = redirectChain(resp)
+	s.metadata.setValidators(u, resp.Header)
+	if result.spool != nil {
+		s.archiveSpooledResponse(resp, result.spool, requestTime)
+		result.spool = nil
+	} else {
+		s.archiveResponse(resp, result.data, requestTime)
+	}
+
+	return result, nil
+}
+
+// redirectChain returns the URLs of the redirects that the client followed
+// to get the response, ending with the final URL. It returns nil if the
+// request was not redirected.
+func redirectChain(resp *http.Response) []string {
+	if resp.Request.Response == nil {
+		return nil
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("unexpected HTTP request status code %d", resp.StatusCode)
+	chain := []string{resp.Request.URL.String()}
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		chain = append(chain, req.Response.Request.URL.String())
 	}
+	slices.Reverse(chain)
+	return chain
+}
 
-	buf := &bytes.Buffer{}
-	if _, err := io.Copy(buf, resp.Body); err != nil {
-		return nil, nil, fmt.Errorf("reading HTTP request body: %w", err)
+func (s *Scraper) closeResponseBody(u *url.URL, resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		s.logger.Error("Closing HTTP Request body failed",
+			log.String("url", u.String()),
+			log.Err(err))
 	}
-	return buf.Bytes(), resp.Request.URL, nil
 }
 
 // Headers converts a slice of strings to a http.Header.
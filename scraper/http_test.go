@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,9 +25,14 @@ func TestDownloadURLWithRetries(t *testing.T) {
 	ctx := context.Background()
 	expected := "ok"
 
+	cfg := Config{
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	}
+
 	var retry int
 	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		if retry < maxRetries {
+		if retry < 2 {
 			retry++
 			w.WriteHeader(http.StatusTooManyRequests)
 			return
@@ -39,24 +45,54 @@ func TestDownloadURLWithRetries(t *testing.T) {
 	ur, err := url.Parse(svr.URL)
 	require.NoError(t, err)
 
-	maxRetries = 2
-	retryDelay = time.Millisecond
-
-	var cfg Config
 	logger := log.NewTestLogger(t)
 	s, err := New(logger, cfg)
 	require.NoError(t, err)
 
 	// download works after 2 retries
-	b, urActual, err := s.downloadURLWithRetries(ctx, ur)
+	resp, err := s.downloadURLWithRetries(ctx, ur, nil)
 	require.NoError(t, err)
-	require.NotNil(t, urActual)
-	assert.Equal(t, svr.URL, urActual.String())
-	assert.Equal(t, expected, string(b))
-	assert.Equal(t, retry, maxRetries)
+	require.NotNil(t, resp.url)
+	assert.Equal(t, svr.URL, resp.url.String())
+	assert.Equal(t, expected, string(resp.data))
+	assert.Equal(t, 2, retry)
 
 	// download fails after 3 retries
 	retry = -100
-	_, _, err = s.downloadURLWithRetries(ctx, ur)
+	_, err = s.downloadURLWithRetries(ctx, ur, nil)
 	assert.ErrorIs(t, err, errExhaustedRetries)
 }
+
+func TestDownloadURLMaxResponseSize(t *testing.T) {
+	ctx := context.Background()
+	body := strings.Repeat("a", 100)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// flushing before writing the body omits the content length
+			w.(http.Flusher).Flush()
+		}
+		_, err := fmt.Fprint(w, body)
+		assert.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	cfg := Config{MaxResponseSize: 100}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+
+	ur, err := url.Parse(svr.URL + "/")
+	require.NoError(t, err)
+	resp, err := s.downloadURLWithRetries(ctx, ur, nil)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(resp.data))
+
+	s.config.MaxResponseSize = 99
+	_, err = s.downloadURLWithRetries(ctx, ur, nil)
+	assert.ErrorIs(t, err, errResponseTooLarge)
+
+	ur, err = url.Parse(svr.URL + "/chunked")
+	require.NoError(t, err)
+	_, err = s.downloadURLWithRetries(ctx, ur, nil)
+	assert.ErrorIs(t, err, errResponseTooLarge)
+}
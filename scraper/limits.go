@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+)
+
+var (
+	errLimitReached     = errors.New("crawl limit reached")
+	errResponseTooLarge = errors.New("response body exceeds the maximum size")
+)
+
+// crawlLimits counts the downloads of a crawl and stops the crawl once one
+// of the configured limits is reached.
+type crawlLimits struct {
+	maxPages     uint
+	maxAssets    uint
+	maxTotalSize int64
+
+	mu        sync.Mutex
+	pages     uint
+	assets    uint
+	totalSize int64
+	reached   error                   // the first limit that was reached
+	cancel    context.CancelCauseFunc // cancels the context of the running crawl
+}
+
+func newCrawlLimits(cfg Config) *crawlLimits {
+	return &crawlLimits{
+		maxPages:     cfg.MaxPages,
+		maxAssets:    cfg.MaxAssets,
+		maxTotalSize: cfg.MaxTotalSize,
+	}
+}
+
+// startLimits returns a context for the crawl that gets cancelled once a
+// limit is reached, including the maximum duration of the crawl. The
+// returned function has to be called at the end of the crawl.
+func (s *Scraper) startLimits(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	s.limits.mu.Lock()
+	s.limits.cancel = cancel
+	s.limits.mu.Unlock()
+
+	var timer *time.Timer
+	if duration := s.config.MaxDuration; duration > 0 {
+		timer = time.AfterFunc(duration, func() {
+			s.limitReached(fmt.Errorf("%w: duration of %s", errLimitReached, duration))
+		})
+	}
+
+	return ctx, func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		cancel(nil)
+	}
+}
+
+// reserveDownload counts a page or asset download before it starts. If the
+// maximum number of pages or assets is reached, the crawl is stopped and the
+// context error is returned.
+func (s *Scraper) reserveDownload(ctx context.Context, isAsset bool) error {
+	l := s.limits
+	l.mu.Lock()
+	var reached error
+	switch {
+	case isAsset && l.maxAssets > 0 && l.assets >= l.maxAssets:
+		reached = fmt.Errorf("%w: maximum of %d assets", errLimitReached, l.maxAssets)
+	case !isAsset && l.maxPages > 0 && l.pages >= l.maxPages:
+		reached = fmt.Errorf("%w: maximum of %d pages", errLimitReached, l.maxPages)
+	case isAsset:
+		l.assets++
+	default:
+		l.pages++
+	}
+	l.mu.Unlock()
+
+	if reached == nil {
+		return nil
+	}
+	s.limitReached(reached)
+	return ctx.Err()
+}
+
+// addDownloadedSize adds the size of a downloaded response body to the total
+// size of the crawl. If the maximum total size is exceeded, the crawl is
+// stopped and the context error is returned, the response is not stored.
+func (s *Scraper) addDownloadedSize(ctx context.Context, size int64) error {
+	l := s.limits
+	l.mu.Lock()
+	l.totalSize += size
+	exceeded := l.maxTotalSize > 0 && l.totalSize > l.maxTotalSize
+	l.mu.Unlock()
+
+	if !exceeded {
+		return nil
+	}
+	s.limitReached(fmt.Errorf("%w: maximum total size of %d bytes", errLimitReached, l.maxTotalSize))
+	return ctx.Err()
+}
+
+// limitReached stops the crawl, only the first reached limit is logged.
+func (s *Scraper) limitReached(reached error) {
+	l := s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reached != nil {
+		return
+	}
+	l.reached = reached
+
+	s.logger.Warn("Stopping crawl", log.Err(reached))
+	if l.cancel != nil {
+		l.cancel(reached)
+	}
+}
+
+// reachedLimit returns the first limit that was reached or nil.
+func (s *Scraper) reachedLimit() error {
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	return s.limits.reached
+}
@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitsTestURLs() map[string][]byte {
+	indexPage := []byte(`<html><body>
+<a href="/page1">Page 1</a>
+<a href="/page2">Page 2</a>
+<a href="/page3">Page 3</a>
+<img src="/img/a.png"><img src="/img/b.png"><img src="/img/c.png">
+</body></html>`)
+	page := []byte(`<html><body>page</body></html>`)
+	image := []byte("0123456789")
+
+	fullURL := "https://example.org"
+	return map[string][]byte{
+		fullURL + "/":          indexPage,
+		fullURL + "/page1":     page,
+		fullURL + "/page2":     page,
+		fullURL + "/page3":     page,
+		fullURL + "/img/a.png": image,
+		fullURL + "/img/b.png": image,
+		fullURL + "/img/c.png": image,
+	}
+}
+
+func TestScraperLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		expected []string
+	}{
+		{
+			name: "pages",
+			cfg:  Config{MaxPages: 2},
+			expected: []string{
+				StateDirName + "/example.org.json",
+				"example.org/img/a.png",
+				"example.org/img/b.png",
+				"example.org/img/c.png",
+				"example.org/index.html",
+				"example.org/page1.html",
+			},
+		},
+		{
+			name: "assets",
+			cfg:  Config{MaxAssets: 2},
+			expected: []string{
+				"example.org/img/a.png",
+				"example.org/img/b.png",
+				"example.org/index.html",
+			},
+		},
+		{
+			name: "total size",
+			// the index page and one image fit into the limit
+			cfg: Config{MaxTotalSize: int64(len(newLimitsTestURLs()["https://example.org/"]) + 15)},
+			expected: []string{
+				"example.org/img/a.png",
+				"example.org/index.html",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := storage.NewMemory()
+			cfg := test.cfg
+			cfg.URL = "https://example.org/"
+			cfg.Storage = files
+			cfg.IgnoreRobotsTxt = true
+
+			scraper := newTestScraperWithConfig(t, cfg, newLimitsTestURLs())
+			require.NoError(t, scraper.Start(context.Background()))
+			assert.ErrorIs(t, scraper.reachedLimit(), errLimitReached)
+			assert.Equal(t, test.expected, files.Paths())
+		})
+	}
+}
+
+func TestScraperMaxDuration(t *testing.T) {
+	cfg := Config{
+		URL:             "https://example.org/",
+		IgnoreRobotsTxt: true,
+		MaxDuration:     20 * time.Millisecond,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, nil)
+	scraper.httpDownloader = func(ctx context.Context, _ *url.URL, _ *streamTarget) (*httpResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	require.NoError(t, scraper.Start(context.Background()))
+	assert.ErrorIs(t, scraper.reachedLimit(), errLimitReached)
+}
@@ -0,0 +1,231 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+)
+
+const (
+	// MetadataDirName is the name of the directory in the output directory
+	// that the HTTP validators of all downloads are stored in, in a file
+	// named after the host. It is kept outside of the host directory to not
+	// be served or collide with the files of the website.
+	MetadataDirName = ".goscrape-metadata"
+	// PageCacheDirName is the name of the directory in the output directory
+	// that unmodified copies of the pages and stylesheets are stored in, in a
+	// subdirectory named after the host.
+	PageCacheDirName = ".goscrape-cache"
+)
+
+// urlMetadata contains the HTTP validators and the storage locations of a
+// downloaded URL.
+type urlMetadata struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
+	// CachePath is the path of the unmodified copy of a page or stylesheet,
+	// it is used to parse the links if the file has not been modified.
+	CachePath string `json:"cache_path,omitempty"`
+}
+
+// hasValidators returns whether the metadata contains a validator that can
+// be used for a conditional request.
+func (m urlMetadata) hasValidators() bool {
+	return m.ETag != "" || m.LastModified != ""
+}
+
+// metadataStore is a sidecar store of the metadata of all downloaded URLs
+// that is used for incremental scrapes. All methods can be called on a nil
+// store, which is used if incremental scraping is disabled.
+type metadataStore struct {
+	mu       sync.Mutex
+	dir      string
+	filePath string
+	entries  map[string]urlMetadata
+}
+
+func newMetadataStore(outputDirectory string, u *url.URL) *metadataStore {
+	host := strings.ReplaceAll(u.Host, ":", "_")
+	return &metadataStore{
+		dir:      filepath.Join(outputDirectory, PageCacheDirName, host),
+		filePath: filepath.Join(outputDirectory, MetadataDirName, host+".json"),
+		entries:  map[string]urlMetadata{},
+	}
+}
+
+// load reads the metadata file from the storage if it exists.
+func (m *metadataStore) load(st storage.Storage) error {
+	if m == nil {
+		return nil
+	}
+
+	data, err := st.ReadFile(m.filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading metadata: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		return fmt.Errorf("unmarshaling metadata: %w", err)
+	}
+	return nil
+}
+
+// save writes the metadata file to the storage.
+func (m *metadataStore) save(st storage.Storage) error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	data, err := json.Marshal(m.entries)
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	if err := st.WriteFile(m.filePath, data); err != nil {
+		return fmt.Errorf("writing metadata: %w", err)
+	}
+	return nil
+}
+
+// get returns the metadata of the URL.
+func (m *metadataStore) get(u *url.URL) (urlMetadata, bool) {
+	if m == nil {
+		return urlMetadata{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[u.String()]
+	return entry, ok
+}
+
+// update changes the metadata of the URL using the given function.
+func (m *metadataStore) update(u *url.URL, fn func(entry *urlMetadata)) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entries[u.String()]
+	fn(&entry)
+	m.entries[u.String()] = entry
+}
+
+// setValidators stores the validators of a successful response.
+func (m *metadataStore) setValidators(u *url.URL, header http.Header) {
+	m.update(u, func(entry *urlMetadata) {
+		entry.ETag = header.Get("ETag")
+		entry.LastModified = header.Get("Last-Modified")
+		entry.ContentType = header.Get("Content-Type")
+	})
+}
+
+// setFilePath stores the path of the file that the URL content was written to.
+func (m *metadataStore) setFilePath(u *url.URL, filePath string) {
+	m.update(u, func(entry *urlMetadata) {
+		entry.FilePath = filePath
+	})
+}
+
+// cachePath returns the path of the unmodified copy of a page or stylesheet.
+func (m *metadataStore) cachePath(u *url.URL) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(u.String()))
+	return filepath.Join(m.dir, fmt.Sprintf("%016x%s", h.Sum64(), PageExtension))
+}
+
+// canRevalidate returns whether an existing file of the URL can be revalidated
+// using a conditional request.
+func (s *Scraper) canRevalidate(u *url.URL) bool {
+	entry, ok := s.metadata.get(u)
+	return ok && entry.hasValidators()
+}
+
+// conditionalHeaders sets the headers for a conditional request if validators
+// for the URL are known and the local copy of it still exists.
+func (s *Scraper) conditionalHeaders(u *url.URL, header http.Header) {
+	entry, ok := s.metadata.get(u)
+	if !ok || !entry.hasValidators() || entry.FilePath == "" {
+		return
+	}
+	if !s.storage.Exists(entry.FilePath) {
+		return
+	}
+	if entry.CachePath != "" && !s.storage.Exists(entry.CachePath) {
+		return
+	}
+
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// storePageCache writes an unmodified copy of a page or stylesheet that is
+// used to parse its links in a following scrape if it has not been modified.
+func (s *Scraper) storePageCache(u *url.URL, data []byte) {
+	if s.metadata == nil {
+		return
+	}
+
+	cachePath := s.metadata.cachePath(u)
+	if err := s.storage.WriteFile(cachePath, data); err != nil {
+		s.logger.Error("Writing page cache file failed",
+			log.String("url", u.String()),
+			log.String("file", cachePath),
+			log.Err(err))
+		return
+	}
+
+	s.metadata.update(u, func(entry *urlMetadata) {
+		entry.CachePath = cachePath
+	})
+}
+
+// cachedPage returns the unmodified copy of a page or stylesheet and whether
+// it exists. Binary files that are linked like pages do not have a cached copy.
+func (s *Scraper) cachedPage(u *url.URL) ([]byte, bool) {
+	entry, _ := s.metadata.get(u)
+	if entry.CachePath == "" {
+		return nil, false
+	}
+
+	data, err := s.storage.ReadFile(entry.CachePath)
+	if err != nil {
+		s.logger.Error("Reading page cache file failed",
+			log.String("url", u.String()),
+			log.String("file", entry.CachePath),
+			log.Err(err))
+		return nil, false
+	}
+	return data, true
+}
+
+// saveMetadata saves the metadata store and logs errors.
+func (s *Scraper) saveMetadata() {
+	if err := s.metadata.save(s.storage); err != nil {
+		s.logger.Error("Saving metadata failed", log.Err(err))
+	}
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperIncremental(t *testing.T) {
+	pages := map[string]string{
+		"/":          `<html><head><link rel="stylesheet" href="/style.css"></head><body><a href="/page2">2</a><img src="/img.png"/></body></html>`,
+		"/page2":     `<html><body><a href="/">index</a></body></html>`,
+		"/img.png":   "image",
+		"/style.css": `body { background: url("/bg.png"); }`,
+		"/bg.png":    "background",
+	}
+
+	var mu sync.Mutex
+	statusCodes := map[int]int{}
+	conditional := map[string]int{}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("If-None-Match") != "" {
+			conditional[r.URL.Path]++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			statusCodes[http.StatusNotModified]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		statusCodes[http.StatusOK]++
+		if strings.HasSuffix(r.URL.Path, ".css") {
+			w.Header().Set("Content-Type", "text/css")
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer svr.Close()
+
+	cfg := Config{
+		URL:             svr.URL + "/",
+		OutputDirectory: t.TempDir(),
+		IgnoreRobotsTxt: true,
+		Incremental:     true,
+	}
+
+	scrape := func() {
+		s, err := New(log.NewTestLogger(t), cfg)
+		require.NoError(t, err)
+		require.NoError(t, s.Start(context.Background()))
+	}
+
+	scrape()
+	assert.Equal(t, map[int]int{http.StatusOK: 5}, statusCodes)
+	assert.Empty(t, conditional)
+
+	host := strings.TrimPrefix(svr.URL, "http://")
+	indexFile := filepath.Join(cfg.OutputDirectory, host, "index.html")
+	before, err := os.ReadFile(indexFile)
+	require.NoError(t, err)
+
+	// the metadata and the page cache are stored next to the website
+	assert.FileExists(t, filepath.Join(cfg.OutputDirectory, MetadataDirName, strings.ReplaceAll(host, ":", "_")+".json"))
+	assert.DirExists(t, filepath.Join(cfg.OutputDirectory, PageCacheDirName, strings.ReplaceAll(host, ":", "_")))
+	entries, err := os.ReadDir(filepath.Dir(indexFile))
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"bg.png", "img.png", "index.html", "page2.html", "style.css"}, names)
+
+	// second scrape revalidates all files and follows the links of the
+	// unmodified pages and stylesheets
+	clear(statusCodes)
+	scrape()
+	assert.Equal(t, map[int]int{http.StatusNotModified: 5}, statusCodes)
+	assert.Equal(t, map[string]int{"/": 1, "/page2": 1, "/img.png": 1, "/style.css": 1, "/bg.png": 1}, conditional)
+
+	after, err := os.ReadFile(indexFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	// a deleted file that is referenced by an unmodified stylesheet is
+	// downloaded again
+	require.NoError(t, os.Remove(filepath.Join(filepath.Dir(indexFile), "bg.png")))
+	clear(statusCodes)
+	scrape()
+	assert.Equal(t, map[int]int{http.StatusNotModified: 4, http.StatusOK: 1}, statusCodes)
+	assert.FileExists(t, filepath.Join(filepath.Dir(indexFile), "bg.png"))
+}
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/cornelk/gotokit/app"
+)
+
+// hostLimiter contains the politeness state of a single host.
+type hostLimiter struct {
+	next  time.Time     // earliest time that the next request can be sent
+	delay time.Duration // crawl delay requested by the host
+	slots chan struct{} // limits the in-flight requests, nil for unlimited
+}
+
+// politeness limits the request rate and the number of in-flight requests
+// per host.
+type politeness struct {
+	interval time.Duration // minimum interval between two requests to a host
+	jitter   time.Duration // maximum random delay added to the interval
+	maxConns uint          // maximum in-flight requests per host, 0 for unlimited
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+func newPoliteness(cfg Config) *politeness {
+	interval := cfg.CrawlDelay
+	if cfg.RequestsPerSecond > 0 {
+		rateInterval := time.Duration(float64(time.Second) / cfg.RequestsPerSecond)
+		interval = max(interval, rateInterval)
+	}
+
+	return &politeness{
+		interval: interval,
+		jitter:   cfg.CrawlDelayJitter,
+		maxConns: cfg.MaxConnectionsPerHost,
+		hosts:    map[string]*hostLimiter{},
+	}
+}
+
+// host returns the limiter of the given host, it has to be called with the
+// mutex locked.
+func (p *politeness) host(host string) *hostLimiter {
+	h, ok := p.hosts[host]
+	if !ok {
+		h = &hostLimiter{}
+		if p.maxConns > 0 {
+			h.slots = make(chan struct{}, p.maxConns)
+		}
+		p.hosts[host] = h
+	}
+	return h
+}
+
+// acquire blocks until a connection slot for the host is available. The
+// returned function has to be called to release the slot.
+func (p *politeness) acquire(ctx context.Context, host string) (func(), error) {
+	p.mu.Lock()
+	h := p.host(host)
+	p.mu.Unlock()
+
+	if h.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+		return func() { <-h.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for host connection slot: %w", ctx.Err())
+	}
+}
+
+// wait blocks until the next request to the host is allowed to be sent
+// based on the configured rate limit and crawl delay.
+func (p *politeness) wait(ctx context.Context, host string) error {
+	p.mu.Lock()
+	h := p.host(host)
+	interval := max(p.interval, h.delay)
+	if interval == 0 && p.jitter == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	start := now
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(interval)
+	if p.jitter > 0 {
+		h.next = h.next.Add(rand.N(p.jitter))
+	}
+	p.mu.Unlock()
+
+	if err := app.Sleep(ctx, start.Sub(now)); err != nil {
+		return fmt.Errorf("waiting for crawl delay: %w", err)
+	}
+	return nil
+}
+
+// setHostDelay sets the crawl delay that a host requested, it is used if it
+// is longer than the configured interval.
+func (p *politeness) setHostDelay(host string, delay time.Duration) {
+	p.mu.Lock()
+	p.host(host).delay = delay
+	p.mu.Unlock()
+}
@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolitenessWait(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{
+		RequestsPerSecond: 50,
+		CrawlDelay:        10 * time.Millisecond,
+	}
+	p := newPoliteness(cfg)
+	assert.Equal(t, 20*time.Millisecond, p.interval)
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, p.wait(ctx, "example.org"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	// other hosts are not affected by the delay of example.org
+	start = time.Now()
+	require.NoError(t, p.wait(ctx, "example.com"))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestPolitenessMaxConnections(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{
+		MaxConnectionsPerHost: 2,
+	}
+	p := newPoliteness(cfg)
+
+	var inFlight, maxInFlight atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release, err := p.acquire(ctx, "example.org")
+			assert.NoError(t, err)
+			defer release()
+
+			current := inFlight.Add(1)
+			for {
+				highest := maxInFlight.Load()
+				if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func TestDownloadSlotsOfBusyHost(t *testing.T) {
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-unblock
+		_, _ = fmt.Fprint(w, "slow")
+	}))
+	defer slow.Close()
+	defer close(unblock)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, "fast")
+	}))
+	defer fast.Close()
+
+	cfg := Config{
+		Concurrency:           2,
+		MaxConnectionsPerHost: 1,
+	}
+	s, err := New(log.NewNop(), cfg) // the canceled slow downloads get logged
+	require.NoError(t, err)
+
+	slowURL, err := url.Parse(slow.URL)
+	require.NoError(t, err)
+	fastURL, err := url.Parse(fast.URL)
+	require.NoError(t, err)
+
+	// the second download of the slow host waits for the host slot without
+	// holding the remaining download slot
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for range 2 {
+		go func() {
+			_, _ = s.download(ctx, slowURL, nil)
+		}()
+	}
+	<-started
+	time.Sleep(10 * time.Millisecond)
+
+	fastCtx, fastCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer fastCancel()
+	resp, err := s.download(fastCtx, fastURL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "fast", string(resp.data))
+}
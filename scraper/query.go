@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxQuerySuffixLength is the maximum length of a query that is added
+// readable to a file name, longer queries are replaced by a hash.
+const maxQuerySuffixLength = 64
+
+// queryFilter normalizes the query strings of URLs for file names and the
+// duplicate detection.
+type queryFilter struct {
+	// ignored contains the names of query parameters to ignore, a name that
+	// ends with * matches all parameters with that prefix.
+	ignored []string
+}
+
+func (f queryFilter) isIgnored(key string) bool {
+	for _, ignored := range f.ignored {
+		if prefix, ok := strings.CutSuffix(ignored, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+			continue
+		}
+		if key == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns the query without the ignored parameters and with the
+// parameters sorted by name, so that the parameter order does not result in
+// duplicate downloads. Queries that can not be parsed are returned unchanged.
+func (f queryFilter) normalize(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	for key := range values {
+		if f.isIgnored(key) {
+			delete(values, key)
+		}
+	}
+	return values.Encode()
+}
+
+// fileNameWithQuery adds the normalized query of the URL to the file name
+// before the file extension. Queries that only contain characters that are
+// safe to use in file names and links are added readable like
+// list_page=2.html, all other queries are added as hash.
+func (f queryFilter) fileNameWithQuery(fileName, rawQuery string) string {
+	query := f.normalize(rawQuery)
+	if query == "" {
+		return fileName
+	}
+
+	suffix := query
+	if len(query) > maxQuerySuffixLength || strings.IndexFunc(query, isUnsafeQueryRune) != -1 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(query))
+		suffix = fmt.Sprintf("%08x", h.Sum32())
+	}
+
+	ext := path.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "_" + suffix + ext
+}
+
+func isUnsafeQueryRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r == '-', r == '_', r == '.', r == '=', r == '&':
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryFilter(t *testing.T) {
+	filter := queryFilter{ignored: []string{"utm_*", "sid"}}
+
+	tests := []struct {
+		rawQuery string
+		query    string
+		fileName string
+	}{
+		{"", "", "list.html"},
+		{"page=2", "page=2", "list_page=2.html"},
+		{"b=2&a=1", "a=1&b=2", "list_a=1&b=2.html"},
+		{"page=2&utm_source=mail&utm_medium=x&sid=123", "page=2", "list_page=2.html"},
+		{"utm_source=mail", "", "list.html"},
+		{"sidebar=1", "sidebar=1", "list_sidebar=1.html"},
+		{"q=hello+world", "q=hello+world", "list_af0391a2.html"},
+		{"a=%zz", "a=%zz", "list_ea359b66.html"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rawQuery, func(t *testing.T) {
+			assert.Equal(t, test.query, filter.normalize(test.rawQuery))
+			assert.Equal(t, test.fileName, filter.fileNameWithQuery("list.html", test.rawQuery))
+		})
+	}
+}
+
+func TestScraperQueryPages(t *testing.T) {
+	indexPage := []byte(`<html><body>
+<a href="/list?page=1">1</a>
+<a href="/list?page=2&utm_source=index">2</a>
+<a href="/list?utm_source=index&page=2">2</a>
+<img src="/img.png?v=3"/>
+</body></html>`)
+	listPage := []byte(`<html><body><a href="?page=2">next</a></body></html>`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                             indexPage,
+		fullURL + "/list?page=1":                  listPage,
+		fullURL + "/list?page=2&utm_source=index": listPage,
+		fullURL + "/img.png?v=3":                  empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:                    fullURL + "/",
+		Storage:                files,
+		IgnoreRobotsTxt:        true,
+		IgnoredQueryParameters: []string{"utm_*"},
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expected := []string{
+		"example.org/img_v=3.png",
+		"example.org/index.html",
+		"example.org/list_page=1.html",
+		"example.org/list_page=2.html",
+	}
+	assert.Equal(t, expected, files.Paths())
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="list_page=1.html">1</a>`)
+	assert.Contains(t, string(index), `<a href="list_page=2.html">2</a>`)
+	assert.Contains(t, string(index), `<img src="img_v=3.png"/>`)
+
+	list, err := files.ReadFile("example.org/list_page=1.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(list), `<a href="list_page=2.html">next</a>`)
+}
@@ -0,0 +1,91 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/cornelk/gotokit/log"
+	"golang.org/x/net/html"
+)
+
+// redirectStub is the page that is written for a URL that redirected to
+// another page, it forwards to the local file of the final URL.
+const redirectStub = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url=%[1]s">
+<link rel="canonical" href="%[1]s">
+<title>Redirect</title>
+</head>
+<body>
+<a href="%[1]s">%[1]s</a>
+</body>
+</html>
+`
+
+// redirectedURL returns the URL to store the response of a page download
+// under. This is the final URL of a redirect if it leads to a page host,
+// otherwise the requested URL. Redirects that only change parts of the URL
+// that are ignored for duplicate detection, like a trailing slash, are
+// not followed.
+func (s *Scraper) redirectedURL(requested, final *url.URL) *url.URL {
+	if final == nil || !s.domains.isPageHost(s.URL.Host, final.Host) {
+		return requested
+	}
+	if s.processedKey(final) == s.processedKey(requested) {
+		return requested
+	}
+	return final
+}
+
+// writeRedirectStub writes a redirect stub page to the file of the requested
+// URL that forwards to the file of the final URL, so that links to the
+// requested URL keep working offline.
+func (s *Scraper) writeRedirectStub(requested, final *url.URL) {
+	if s.config.WARCOnly {
+		return
+	}
+
+	s.writeStub(requested, s.getFilePath(requested, true), s.getFilePath(final, true))
+}
+
+// writeDocumentStub writes a redirect stub page to the page file of a URL
+// whose document is no page and was stored under another file name, like
+// data.json for JSON served at /data. Hyperlinks are relinked to the page
+// file before the Content-Type is known, the stub keeps them working offline.
+func (s *Scraper) writeDocumentStub(u *url.URL, filePath string) {
+	s.writeStub(u, s.getFilePath(u, true), filePath)
+}
+
+// writeStub writes a redirect stub page to the stub path that forwards to
+// the file at the target path.
+func (s *Scraper) writeStub(u *url.URL, stubPath, targetPath string) {
+	if stubPath == targetPath {
+		return
+	}
+
+	relative, err := filepath.Rel(filepath.Dir(stubPath), targetPath)
+	if err != nil {
+		s.logger.Error("Resolving redirect target failed",
+			log.String("url", u.String()),
+			log.Err(err))
+		return
+	}
+	target := &url.URL{Path: filepath.ToSlash(relative)}
+	data := fmt.Sprintf(redirectStub, html.EscapeString(target.String()))
+
+	if err := s.storage.WriteFile(stubPath, []byte(data)); err != nil {
+		s.logger.Error("Writing redirect stub failed",
+			log.String("url", u.String()),
+			log.String("file", stubPath),
+			log.Err(err))
+		return
+	}
+
+	s.logger.Debug("Redirect stub written",
+		log.String("url", u.String()),
+		log.String("target", targetPath),
+		log.String("file", stubPath))
+}
@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperRedirectStubs(t *testing.T) {
+	var targetRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `<a href="/old">Old</a><a href="/new">New</a><a href="/moved">Moved</a>`)
+		assert.NoError(t, err)
+	})
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.Handle("/moved", http.RedirectHandler("/sub/target", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `new`)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("/sub/target", func(w http.ResponseWriter, _ *http.Request) {
+		targetRequests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `target`)
+		assert.NoError(t, err)
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             svr.URL + "/",
+		IgnoreRobotsTxt: true,
+		Storage:         files,
+	}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+	hostDir := u.Host
+
+	data, err := files.ReadFile(filepath.Join(hostDir, "sub", "target.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "target", string(data))
+	// the final URL of the redirect is marked as processed
+	assert.Equal(t, int32(1), targetRequests.Load())
+
+	data, err = files.ReadFile(filepath.Join(hostDir, "moved.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<meta http-equiv="refresh" content="0; url=sub/target.html">`)
+
+	data, err = files.ReadFile(filepath.Join(hostDir, "new.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	data, err = files.ReadFile(filepath.Join(hostDir, "old.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<a href="new.html">new.html</a>`)
+}
+
+func TestScraperRedirectRules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `<a href="/direct.pdf">Direct</a><a href="/old">Old</a><a href="/moved">Moved</a>`)
+		assert.NoError(t, err)
+	})
+	mux.Handle("/old", http.RedirectHandler("/new.pdf", http.StatusFound))
+	mux.Handle("/moved", http.RedirectHandler("/page", http.StatusFound))
+	pdf := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, err := fmt.Fprint(w, `%PDF-1.4`)
+		assert.NoError(t, err)
+	}
+	mux.HandleFunc("/direct.pdf", pdf)
+	mux.HandleFunc("/new.pdf", pdf)
+	mux.HandleFunc("/page", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `page`)
+		assert.NoError(t, err)
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             svr.URL + "/",
+		IgnoreRobotsTxt: true,
+		Storage:         files,
+		PageRules:       []string{"exclude type=application/pdf"},
+	}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+	hostDir := u.Host
+
+	// the redirect target is excluded by the rules of the requested URL and
+	// gets no redirect stub
+	expected := []string{
+		hostDir + "/index.html",
+		hostDir + "/moved.html",
+		hostDir + "/page.html",
+	}
+	assert.Equal(t, expected, files.Paths())
+	assert.Empty(t, s.pendingRules)
+}
+
+func TestRedirectedURL(t *testing.T) {
+	cfg := Config{URL: "https://example.org/"}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		requested string
+		final     string
+		expected  string
+	}{
+		{"https://example.org/old", "https://example.org/new", "https://example.org/new"},
+		{"https://example.org/dir", "https://example.org/dir/", "https://example.org/dir"},
+		{"https://example.org/page", "https://other.org/page", "https://example.org/page"},
+	}
+
+	for _, test := range tests {
+		requested, err := url.Parse(test.requested)
+		require.NoError(t, err)
+		final, err := url.Parse(test.final)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, s.redirectedURL(requested, final).String(), test.requested)
+	}
+}
@@ -0,0 +1,227 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxReportReferrers is the maximum number of referring pages that are
+// listed for a URL in the crawl report.
+const maxReportReferrers = 10
+
+// types of the URLs of a crawl report.
+const (
+	ReportTypePage  = "page"
+	ReportTypeAsset = "asset"
+)
+
+// Report is the report of a crawl, it lists all downloaded URLs.
+type Report struct {
+	URL        string    `json:"url"`
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"duration_ms"`
+
+	Pages    int   `json:"pages"`
+	Assets   int   `json:"assets"`
+	Failures int   `json:"failures"`
+	Bytes    int64 `json:"bytes"`
+
+	// Redirects maps the URLs that were redirected to their final URL.
+	Redirects map[string]string `json:"redirects,omitempty"`
+	Entries   []ReportEntry     `json:"urls"`
+}
+
+// ReportEntry is the result of the download of a page or asset URL.
+type ReportEntry struct {
+	URL        string   `json:"url"`
+	Type       string   `json:"type"`
+	StatusCode int      `json:"status_code,omitempty"`
+	Referrers  []string `json:"referrers,omitempty"` // pages or stylesheets that link to the URL
+	Redirects  []string `json:"redirects,omitempty"` // URLs of the followed redirects, ending with the final URL
+	Bytes      int64    `json:"bytes"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Failed returns whether the download of the URL failed.
+func (e ReportEntry) Failed() bool {
+	return e.Error != ""
+}
+
+// crawlReport collects the results of the downloads of a crawl and the
+// pages that link to the downloaded URLs.
+type crawlReport struct {
+	mu       sync.Mutex
+	started  time.Time
+	finished time.Time
+	// key is the URL of a downloaded page or asset
+	entries map[string]*ReportEntry
+	// key is the URL of a linked page or asset, value the referring URLs
+	referrers map[string][]string
+}
+
+func newCrawlReport() *crawlReport {
+	return &crawlReport{
+		entries:   map[string]*ReportEntry{},
+		referrers: map[string][]string{},
+	}
+}
+
+// start sets the start time of the crawl.
+func (r *crawlReport) start() {
+	r.mu.Lock()
+	r.started = time.Now()
+	r.mu.Unlock()
+}
+
+// finish sets the end time of the crawl.
+func (r *crawlReport) finish() {
+	r.mu.Lock()
+	r.finished = time.Now()
+	r.mu.Unlock()
+}
+
+// addReferrer stores that the referrer links to the URL, the fragment of
+// the URL is ignored.
+func (r *crawlReport) addReferrer(u, referrer *url.URL) {
+	linked := *u
+	linked.Fragment = ""
+	key, ref := linked.String(), referrer.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	referrers := r.referrers[key]
+	if len(referrers) < maxReportReferrers && !slices.Contains(referrers, ref) {
+		r.referrers[key] = append(referrers, ref)
+	}
+}
+
+// addDownload stores the result of the download of a page or asset URL.
+// Downloads that were stopped with the crawl are not reported.
+func (r *crawlReport) addDownload(u *url.URL, typ string, resp *httpResponse, err error, duration time.Duration) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	entry := &ReportEntry{
+		URL:        u.String(),
+		Type:       typ,
+		DurationMS: duration.Milliseconds(),
+	}
+	if resp != nil {
+		entry.StatusCode = resp.statusCode
+		entry.Redirects = resp.redirects
+		entry.Bytes = resp.bodySize()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		var statusErr *statusCodeError
+		if errors.As(err, &statusErr) {
+			entry.StatusCode = statusErr.code
+		}
+	}
+
+	r.mu.Lock()
+	r.entries[entry.URL] = entry
+	r.mu.Unlock()
+}
+
+// Report returns the report of the crawl, it can be called while the crawl
+// is running.
+func (s *Scraper) Report() Report {
+	r := s.report
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finished := r.finished
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+	report := Report{
+		URL:        s.config.URL,
+		Started:    r.started,
+		DurationMS: finished.Sub(r.started).Milliseconds(),
+		Entries:    make([]ReportEntry, 0, len(r.entries)),
+	}
+
+	for _, entry := range r.entries {
+		e := *entry
+		e.Referrers = slices.Clone(r.referrers[e.URL])
+		report.Entries = append(report.Entries, e)
+
+		if e.Type == ReportTypePage {
+			report.Pages++
+		} else {
+			report.Assets++
+		}
+		if e.Failed() {
+			report.Failures++
+		}
+		report.Bytes += e.Bytes
+		if len(e.Redirects) > 0 {
+			if report.Redirects == nil {
+				report.Redirects = map[string]string{}
+			}
+			report.Redirects[e.URL] = e.Redirects[len(e.Redirects)-1]
+		}
+	}
+
+	slices.SortFunc(report.Entries, func(a, b ReportEntry) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return report
+}
+
+// Summary returns a human-readable summary of the report that lists the
+// failed URLs and the pages that link to them.
+func (r Report) Summary() string {
+	var sb strings.Builder
+	duration := time.Duration(r.DurationMS) * time.Millisecond
+	fmt.Fprintf(&sb, "Crawl of %s finished in %s:\n", r.URL, duration)
+	fmt.Fprintf(&sb, "  %d pages, %d assets, %s downloaded, %d redirects, %d failures\n",
+		r.Pages, r.Assets, formatBytes(r.Bytes), len(r.Redirects), r.Failures)
+
+	if r.Failures == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("Failed URLs:\n")
+	for _, entry := range r.Entries {
+		if !entry.Failed() {
+			continue
+		}
+
+		if entry.StatusCode != 0 {
+			fmt.Fprintf(&sb, "  %d %s %s\n", entry.StatusCode, http.StatusText(entry.StatusCode), entry.URL)
+		} else {
+			fmt.Fprintf(&sb, "  %s: %s\n", entry.URL, entry.Error)
+		}
+		for _, referrer := range entry.Referrers {
+			fmt.Fprintf(&sb, "    linked from %s\n", referrer)
+		}
+	}
+	return sb.String()
+}
+
+// formatBytes returns the size in a human-readable unit.
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperReport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `<a href="/old">Old</a><a href="/missing#top">Missing</a><img src="/img.png">`)
+		assert.NoError(t, err)
+	})
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, err := fmt.Fprint(w, `<a href="/missing">Missing</a>`)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("/img.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, err := fmt.Fprint(w, "png")
+		assert.NoError(t, err)
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	cfg := Config{
+		URL:             svr.URL + "/",
+		IgnoreRobotsTxt: true,
+		Storage:         storage.NewMemory(),
+	}
+	s, err := New(log.NewNop(), cfg) // the missing page gets logged as error
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	report := s.Report()
+	assert.Equal(t, svr.URL+"/", report.URL)
+	assert.Equal(t, 3, report.Pages)
+	assert.Equal(t, 1, report.Assets)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, map[string]string{svr.URL + "/old": svr.URL + "/new"}, report.Redirects)
+
+	entries := map[string]ReportEntry{}
+	for _, entry := range report.Entries {
+		entries[strings.TrimPrefix(entry.URL, svr.URL)] = entry
+	}
+	require.Len(t, entries, 4)
+
+	missing := entries["/missing"]
+	assert.Equal(t, ReportTypePage, missing.Type)
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+	assert.True(t, missing.Failed())
+	assert.Equal(t, []string{svr.URL + "/", svr.URL + "/new"}, missing.Referrers)
+
+	old := entries["/old"]
+	assert.Equal(t, http.StatusOK, old.StatusCode)
+	assert.Equal(t, []string{svr.URL + "/old", svr.URL + "/new"}, old.Redirects)
+	assert.False(t, old.Failed())
+
+	img := entries["/img.png"]
+	assert.Equal(t, ReportTypeAsset, img.Type)
+	assert.Equal(t, int64(3), img.Bytes)
+	assert.Equal(t, []string{svr.URL + "/"}, img.Referrers)
+
+	summary := report.Summary()
+	assert.Contains(t, summary, "3 pages, 1 assets")
+	assert.Contains(t, summary, "404 Not Found "+svr.URL+"/missing\n    linked from "+svr.URL+"/\n")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "2.0 MiB", formatBytes(2<<20))
+}
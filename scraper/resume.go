@@ -0,0 +1,144 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+)
+
+var (
+	errIncompleteBody      = errors.New("incomplete response body")
+	errUnexpectedRange     = errors.New("unexpected content range")
+	errRangeNotSatisfiable = errors.New("partial download can not be resumed")
+)
+
+// partialDownload is the partial file of an interrupted download that gets
+// resumed with a range request.
+type partialDownload struct {
+	filePath  string
+	size      int64
+	validator string // ETag or Last-Modified value of the partial file
+}
+
+// partialInfo is stored with a partial file to check on resume that the
+// remaining data belongs to the same version of the file.
+type partialInfo struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// setHeaders requests the data after the partial file. If-Range makes the
+// server send the complete file if it changed since the partial download.
+func (p *partialDownload) setHeaders(header http.Header) {
+	header.Set("Range", fmt.Sprintf("bytes=%d-", p.size))
+	header.Set("If-Range", p.validator)
+}
+
+// resumablePartial returns the partial file of an interrupted download of
+// the URL to the file path of the stream target. It returns nil if there is
+// none or the partial file can not be resumed. Without a browsable website
+// no partial files are written that could be resumed.
+func (s *Scraper) resumablePartial(u *url.URL, stream *streamTarget) *partialDownload {
+	if stream == nil || stream.resumePath == "" || s.config.WARCOnly {
+		return nil
+	}
+
+	partial, err := storage.OpenPartialFile(s.storage, stream.resumePath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Warn("Reading partial download failed",
+				log.String("file", stream.resumePath),
+				log.Err(err))
+		}
+		return nil
+	}
+	if partial.Size == 0 || partial.Info == nil {
+		return nil
+	}
+
+	var info partialInfo
+	if err := json.Unmarshal(partial.Info, &info); err != nil || info.URL != u.String() {
+		return nil
+	}
+
+	validator := info.ETag
+	if validator == "" {
+		validator = info.LastModified
+	}
+	if validator == "" {
+		return nil
+	}
+
+	return &partialDownload{
+		filePath:  stream.resumePath,
+		size:      partial.Size,
+		validator: validator,
+	}
+}
+
+// resumeInfo returns the info to store with the partial file of a streamed
+// response. It returns nil if the server does not support range requests or
+// the response has no validator that If-Range can use.
+func resumeInfo(u *url.URL, resp *http.Response) []byte {
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return nil
+	}
+
+	info := partialInfo{
+		URL:          u.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if strings.HasPrefix(info.ETag, "W/") {
+		info.ETag = "" // weak validators are not allowed in If-Range
+	}
+	if info.ETag == "" && info.LastModified == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// parseContentRange returns the first byte position and the complete length
+// of a Content-Range header value like "bytes 100-199/200". The complete
+// length is -1 if it is unknown.
+func parseContentRange(value string) (int64, int64, error) {
+	rangeSpec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: unsupported unit", errUnexpectedRange)
+	}
+	positions, length, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: missing complete length", errUnexpectedRange)
+	}
+	first, _, ok := strings.Cut(positions, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: missing range", errUnexpectedRange)
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", errUnexpectedRange, err)
+	}
+	if length == "*" {
+		return start, -1, nil
+	}
+	total, err := strconv.ParseInt(length, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", errUnexpectedRange, err)
+	}
+	return start, total, nil
+}
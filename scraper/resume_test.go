@@ -0,0 +1,173 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const resumeTestETag = `"v1"`
+
+// newResumeTestServer serves a video that supports range requests. The first
+// download of the complete file is interrupted after half of the data if
+// interrupt is set.
+func newResumeTestServer(t *testing.T, body string, interrupt bool) (*httptest.Server, *[]http.Header) {
+	t.Helper()
+
+	var requests []http.Header
+	var interrupted atomic.Bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Clone())
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("ETag", resumeTestETag)
+
+		if interrupt && r.Header.Get("Range") == "" && !interrupted.Swap(true) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, err := w.Write([]byte(body[:len(body)/2]))
+			assert.NoError(t, err)
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "video.mp4", time.Time{}, strings.NewReader(body))
+	}))
+	t.Cleanup(svr.Close)
+	return svr, &requests
+}
+
+func TestDownloadURLResume(t *testing.T) {
+	body := strings.Repeat("0123456789", 200)
+	svr, requests := newResumeTestServer(t, body, true)
+	dir := t.TempDir()
+
+	s, err := New(log.NewTestLogger(t), Config{URL: svr.URL})
+	require.NoError(t, err)
+
+	filePath := filepath.Join(dir, "video.mp4")
+	stream := &streamTarget{
+		filePath:   func(*url.URL, string) string { return filePath },
+		resumePath: filePath,
+	}
+	u, err := url.Parse(svr.URL + "/video.mp4")
+	require.NoError(t, err)
+
+	_, err = s.downloadURLWithRetries(context.Background(), u, stream)
+	require.Error(t, err)
+
+	partial, err := storage.OpenPartialFile(s.storage, filePath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)/2), partial.Size)
+	var info partialInfo
+	require.NoError(t, json.Unmarshal(partial.Info, &info))
+	assert.Equal(t, partialInfo{URL: u.String(), ETag: resumeTestETag}, info)
+
+	resp, err := s.downloadURLWithRetries(context.Background(), u, stream)
+	require.NoError(t, err)
+	require.Len(t, *requests, 2)
+	assert.Equal(t, "bytes=1000-", (*requests)[1].Get("Range"))
+	assert.Equal(t, resumeTestETag, (*requests)[1].Get("If-Range"))
+
+	sum := sha256.Sum256([]byte(body))
+	assert.Equal(t, int64(len(body)), resp.bodySize())
+	assert.Equal(t, body[:sniffLength], string(resp.bodyStart()))
+	assert.Equal(t, hex.EncodeToString(sum[:]), resp.digest)
+	assert.Equal(t, filePath, s.commitFile(u, resp))
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+	assert.NoFileExists(t, filePath+storage.PartialFileSuffix)
+	assert.NoFileExists(t, filePath+storage.PartialInfoSuffix)
+}
+
+func TestDownloadURLResumeFallback(t *testing.T) {
+	body := strings.Repeat("0123456789", 200)
+
+	tests := []struct {
+		name        string
+		partialSize int
+		etag        string
+	}{
+		{"changed file", 1000, `"v0"`},
+		{"unsatisfiable range", 3000, resumeTestETag},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr, requests := newResumeTestServer(t, body, false)
+			dir := t.TempDir()
+
+			s, err := New(log.NewTestLogger(t), Config{URL: svr.URL})
+			require.NoError(t, err)
+
+			u, err := url.Parse(svr.URL + "/video.mp4")
+			require.NoError(t, err)
+			filePath := filepath.Join(dir, "video.mp4")
+			info, err := json.Marshal(partialInfo{URL: u.String(), ETag: test.etag})
+			require.NoError(t, err)
+
+			file, err := storage.CreateFile(s.storage, filePath, info)
+			require.NoError(t, err)
+			_, err = file.Write(bytes.Repeat([]byte("x"), test.partialSize))
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			stream := &streamTarget{
+				filePath:   func(*url.URL, string) string { return filePath },
+				resumePath: filePath,
+			}
+			resp, err := s.downloadURLWithRetries(context.Background(), u, stream)
+			require.NoError(t, err)
+			assert.NotEmpty(t, (*requests)[0].Get("Range"))
+			assert.Equal(t, filePath, s.commitFile(u, resp))
+
+			data, err := os.ReadFile(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(data))
+		})
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value string
+		start int64
+		total int64
+		err   bool
+	}{
+		{"bytes 100-199/200", 100, 200, false},
+		{"bytes 0-99/*", 0, -1, false},
+		{"bytes */200", 0, 0, true},
+		{"items 0-1/2", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		start, total, err := parseContentRange(test.value)
+		if test.err {
+			assert.Error(t, err, test.value)
+			continue
+		}
+		require.NoError(t, err, test.value)
+		assert.Equal(t, test.start, start, test.value)
+		assert.Equal(t, test.total, total, test.value)
+	}
+}
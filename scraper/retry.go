@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cornelk/gotokit/set"
+)
+
+const (
+	defaultMaxAttempts   = 10
+	defaultRetryDelay    = time.Second
+	defaultRetryMaxDelay = time.Minute
+)
+
+var (
+	errExhaustedRetries = errors.New("exhausted retries")
+	errRetryConfig      = errors.New("invalid retry configuration")
+)
+
+// defaultRetryStatusCodes contains the response status codes that are retried
+// if none are configured, they match the codes that curl retries.
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy decides which failed requests are retried and how long to wait
+// before the next attempt.
+type retryPolicy struct {
+	maxAttempts   uint
+	delay         time.Duration // delay before the first retry
+	maxDelay      time.Duration // maximum delay between two attempts
+	jitter        float64       // fraction of the delay that is randomized
+	statusCodes   set.Set[int]
+	networkErrors bool
+}
+
+// validateRetryConfig returns an error if a retry delay is negative or the
+// jitter is outside of 0 to 1.
+func validateRetryConfig(cfg Config) error {
+	switch {
+	case cfg.RetryDelay < 0:
+		return fmt.Errorf("%w: negative retry delay %s", errRetryConfig, cfg.RetryDelay)
+	case cfg.RetryMaxDelay < 0:
+		return fmt.Errorf("%w: negative maximum retry delay %s", errRetryConfig, cfg.RetryMaxDelay)
+	case cfg.RetryJitter < 0 || cfg.RetryJitter > 1:
+		return fmt.Errorf("%w: jitter %g is not between 0 and 1", errRetryConfig, cfg.RetryJitter)
+	default:
+		return nil
+	}
+}
+
+func newRetryPolicy(cfg Config) retryPolicy {
+	p := retryPolicy{
+		maxAttempts:   cfg.MaxAttempts,
+		delay:         cfg.RetryDelay,
+		maxDelay:      cfg.RetryMaxDelay,
+		jitter:        cfg.RetryJitter,
+		statusCodes:   set.NewFromSlice(cfg.RetryStatusCodes),
+		networkErrors: cfg.RetryNetworkErrors,
+	}
+	if p.maxAttempts == 0 {
+		p.maxAttempts = defaultMaxAttempts
+	}
+	if p.delay == 0 {
+		p.delay = defaultRetryDelay
+	}
+	if p.maxDelay == 0 {
+		p.maxDelay = defaultRetryMaxDelay
+	}
+	if cfg.RetryStatusCodes == nil {
+		p.statusCodes = set.NewFromSlice(defaultRetryStatusCodes)
+	}
+	return p
+}
+
+// retryableError is returned by a failed attempt of a request that can be
+// retried.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration // delay requested by the server, 0 if none
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// statusError returns the error for an unexpected response status, it can be
+// retried if the policy contains the status code.
+func (p retryPolicy) statusError(err error, resp *http.Response) error {
+	if !p.statusCodes.Contains(resp.StatusCode) {
+		return err
+	}
+	return &retryableError{
+		err:        err,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// networkError returns the error for a failed request or interrupted
+// response body, it can be retried if the policy retries network errors and
+// the error is transient. Errors caused by the stopped crawl and permanent
+// errors like failed DNS lookups, invalid certificates or redirect loops are
+// returned unchanged.
+func (p retryPolicy) networkError(ctx context.Context, err error) error {
+	if !p.networkErrors || ctx.Err() != nil || !isTransientError(err) {
+		return err
+	}
+	return &retryableError{err: err}
+}
+
+// isTransientError returns whether the error is a timeout, a refused or
+// reset connection or a connection that was closed before the response was
+// received completely.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) || // the server closed the connection without a response
+		errors.Is(err, errIncompleteBody)
+}
+
+// backoff returns the delay before the given retry, starting at 1. The delay
+// doubles on each retry up to the maximum delay, a Retry-After delay of the
+// server is used if it is longer.
+func (p retryPolicy) backoff(retry uint, retryAfter time.Duration) time.Duration {
+	delay := p.maxDelay
+	// comparing with the shifted maximum delay avoids an overflow of the
+	// shifted delay
+	if shift := retry - 1; shift < 63 && p.delay <= p.maxDelay>>shift {
+		delay = p.delay << shift
+	}
+	if p.jitter > 0 {
+		randomized := time.Duration(float64(delay) * p.jitter)
+		delay = delay - randomized + rand.N(2*randomized+1)
+	}
+
+	delay = max(delay, retryAfter)
+	return min(delay, p.maxDelay)
+}
+
+// parseRetryAfter returns the delay of a Retry-After header value, which is
+// either a number of seconds or an HTTP date. It returns 0 for an invalid or
+// past value.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	return max(date.Sub(now), 0)
+}
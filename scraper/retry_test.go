@@ -0,0 +1,235 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 3 ", 3 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseRetryAfter(test.value, now), test.value)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := newRetryPolicy(Config{
+		MaxAttempts:   5,
+		RetryDelay:    time.Second,
+		RetryMaxDelay: 5 * time.Second,
+	})
+
+	assert.Equal(t, time.Second, p.backoff(1, 0))
+	assert.Equal(t, 2*time.Second, p.backoff(2, 0))
+	assert.Equal(t, 4*time.Second, p.backoff(3, 0))
+	assert.Equal(t, 5*time.Second, p.backoff(4, 0))
+	assert.Equal(t, 5*time.Second, p.backoff(100, 0))
+
+	// Retry-After is used if it is longer, up to the maximum delay
+	assert.Equal(t, 3*time.Second, p.backoff(1, 3*time.Second))
+	assert.Equal(t, 2*time.Second, p.backoff(2, time.Second))
+	assert.Equal(t, 5*time.Second, p.backoff(1, time.Hour))
+
+	p.jitter = 0.5
+	for range 100 {
+		delay := p.backoff(2, 0)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, 3*time.Second)
+	}
+
+	// the doubled delay of late retries exceeds the range of time.Duration
+	p = newRetryPolicy(Config{
+		MaxAttempts:   100,
+		RetryDelay:    10 * time.Second,
+		RetryMaxDelay: time.Minute,
+		RetryJitter:   0.2,
+	})
+	for retry := uint(1); retry < 100; retry++ {
+		delay := p.backoff(retry, 0)
+		assert.Positive(t, delay, retry)
+		assert.LessOrEqual(t, delay, time.Minute, retry)
+	}
+}
+
+func TestValidateRetryConfig(t *testing.T) {
+	require.NoError(t, validateRetryConfig(Config{}))
+	require.NoError(t, validateRetryConfig(Config{RetryDelay: time.Second, RetryMaxDelay: time.Minute, RetryJitter: 1}))
+
+	for _, cfg := range []Config{
+		{RetryDelay: -time.Second},
+		{RetryMaxDelay: -time.Second},
+		{RetryJitter: -0.1},
+		{RetryJitter: 1.5},
+	} {
+		require.ErrorIs(t, validateRetryConfig(cfg), errRetryConfig)
+
+		cfg.URL = "https://example.org"
+		_, err := New(log.NewNop(), cfg)
+		require.ErrorIs(t, err, errRetryConfig)
+	}
+}
+
+func TestDownloadURLRetryPolicy(t *testing.T) {
+	var requests atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := requests.Add(1)
+		switch r.URL.Path {
+		case "/unavailable":
+			if attempt == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+		case "/reset":
+			if attempt == 1 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				assert.NoError(t, conn.Close())
+				return
+			}
+
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		}
+		_, err := fmt.Fprint(w, "ok")
+		assert.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	tests := []struct {
+		path          string
+		networkErrors bool
+		requests      int32
+		success       bool
+	}{
+		{"/unavailable", false, 2, true},
+		{"/reset", true, 2, true},
+		{"/reset", false, 1, false},
+		{"/missing", true, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %t", test.path, test.networkErrors), func(t *testing.T) {
+			requests.Store(0)
+			cfg := Config{
+				MaxAttempts:        3,
+				RetryDelay:         time.Millisecond,
+				RetryNetworkErrors: test.networkErrors,
+			}
+			s, err := New(log.NewTestLogger(t), cfg)
+			require.NoError(t, err)
+
+			u, err := url.Parse(svr.URL + test.path)
+			require.NoError(t, err)
+			resp, err := s.downloadURLWithRetries(context.Background(), u, nil)
+			assert.Equal(t, test.requests, requests.Load())
+			if !test.success {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ok", string(resp.data))
+		})
+	}
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	p := newRetryPolicy(Config{})
+	assert.Equal(t, uint(defaultMaxAttempts), p.maxAttempts)
+	assert.Equal(t, defaultRetryDelay, p.delay)
+	assert.Equal(t, defaultRetryMaxDelay, p.maxDelay)
+	assert.True(t, p.statusCodes.Contains(http.StatusTooManyRequests))
+
+	p = newRetryPolicy(Config{MaxAttempts: 1})
+	assert.Equal(t, uint(1), p.maxAttempts)
+}
+
+func TestRetryPolicyNetworkError(t *testing.T) {
+	p := newRetryPolicy(Config{RetryNetworkErrors: true})
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"timeout", &url.Error{Op: "Get", URL: "http://example.org", Err: &net.DNSError{IsTimeout: true}}, true},
+		{"refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"reset", fmt.Errorf("reading body: %w", syscall.ECONNRESET), true},
+		{"unexpected EOF", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), true},
+		{"DNS", &url.Error{Op: "Get", URL: "http://missing.invalid", Err: &net.OpError{
+			Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "missing.invalid", IsNotFound: true},
+		}}, false},
+		{"redirect loop", errors.New("stopped after 10 redirects"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var retryErr *retryableError
+			assert.Equal(t, test.retryable, errors.As(p.networkError(ctx, test.err), &retryErr))
+		})
+	}
+}
+
+func TestDownloadURLTLSFailure(t *testing.T) {
+	var connections atomic.Int32
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, "ok")
+	}))
+	svr.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	svr.Config.ErrorLog = stdlog.New(io.Discard, "", 0) // the handshake error gets logged by the server
+	svr.StartTLS()
+	defer svr.Close()
+
+	cfg := Config{
+		RetryDelay:         time.Millisecond,
+		RetryNetworkErrors: true,
+	}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+	_, err = s.downloadURLWithRetries(context.Background(), u, nil)
+
+	var certErr *tls.CertificateVerificationError
+	require.ErrorAs(t, err, &certErr)
+	assert.Equal(t, int32(1), connections.Load())
+}
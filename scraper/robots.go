@@ -0,0 +1,286 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+)
+
+// robotsRule is a single Allow or Disallow rule of a robots.txt group.
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsGroup is a group of rules that apply to a set of user agents.
+type robotsGroup struct {
+	userAgents []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsRules contains the rules of a robots.txt file that apply to the
+// configured user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+// parseRobots parses the content of a robots.txt file and returns the rules
+// of the group that matches the given user agent best. Groups that name the
+// same user agent are merged, the * group is used if no group matches.
+func parseRobots(data []byte, userAgent string) *robotsRules {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	var sitemaps []string
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.userAgents = append(current.userAgents, strings.ToLower(value))
+
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value == "" {
+				continue // an empty disallow allows everything
+			}
+			current.rules = append(current.rules, newRobotsRule(key == "allow", value))
+
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+	}
+
+	rules := matchRobotsGroups(groups, userAgent)
+	rules.sitemaps = sitemaps
+	return rules
+}
+
+// matchRobotsGroups merges all groups that match the user agent with the
+// most specific user agent token, the * group only matches if no other
+// group matches.
+func matchRobotsGroups(groups []*robotsGroup, userAgent string) *robotsRules {
+	userAgent = strings.ToLower(userAgent)
+	bestRank := -1
+	var matched []*robotsGroup
+
+	for _, group := range groups {
+		rank := group.userAgentRank(userAgent)
+		switch {
+		case rank < 0:
+		case rank > bestRank:
+			bestRank = rank
+			matched = []*robotsGroup{group}
+		case rank == bestRank:
+			matched = append(matched, group)
+		}
+	}
+
+	rules := &robotsRules{}
+	for _, group := range matched {
+		rules.rules = append(rules.rules, group.rules...)
+		rules.crawlDelay = max(rules.crawlDelay, group.crawlDelay)
+	}
+	return rules
+}
+
+// userAgentRank returns how specific the group matches the lower case user
+// agent. It returns -1 for no match, 0 for a * match and the length of the
+// longest matching user agent token otherwise.
+func (g *robotsGroup) userAgentRank(userAgent string) int {
+	rank := -1
+	for _, token := range g.userAgents {
+		switch {
+		case token == "*":
+			rank = max(rank, 0)
+		case token != "" && userAgent != "" && strings.Contains(userAgent, token):
+			rank = max(rank, len(token))
+		}
+	}
+	return rank
+}
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	expr := pattern
+	anchored := strings.HasSuffix(expr, "$")
+	if anchored {
+		expr = expr[:len(expr)-1]
+	}
+	expr = strings.ReplaceAll(regexp.QuoteMeta(expr), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+
+	return robotsRule{
+		allow:   allow,
+		pattern: pattern,
+		re:      regexp.MustCompile("^" + expr),
+	}
+}
+
+// allowed returns whether the URL is allowed to be crawled and the rule that
+// decided it. The longest matching rule wins, on a tie Allow rules win.
+func (r *robotsRules) allowed(u *url.URL) (bool, *robotsRule) {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+
+	var decision *robotsRule
+	for i, rule := range r.rules {
+		if !rule.re.MatchString(p) {
+			continue
+		}
+		if decision == nil || len(rule.pattern) > len(decision.pattern) ||
+			(len(rule.pattern) == len(decision.pattern) && rule.allow) {
+			decision = &r.rules[i]
+		}
+	}
+
+	if decision == nil {
+		return true, nil
+	}
+	return decision.allow, decision
+}
+
+// robotsEntry is a cached robots.txt file of a host.
+type robotsEntry struct {
+	mu    sync.Mutex
+	rules *robotsRules // nil until the file was downloaded
+}
+
+// robotsRulesForURL returns the robots.txt rules for the host of the URL,
+// the file is downloaded on the first access for every host. A download
+// that was stopped by the context is not cached and repeated on the next
+// access.
+func (s *Scraper) robotsRulesForURL(ctx context.Context, u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+
+	s.mu.Lock()
+	entry, ok := s.robots[key]
+	if !ok {
+		entry = &robotsEntry{}
+		s.robots[key] = entry
+	}
+	s.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.rules != nil {
+		return entry.rules
+	}
+
+	robotsURL := &url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "/robots.txt",
+	}
+
+	s.logger.Debug("Downloading robots.txt", log.String("url", robotsURL.String()))
+	resp, err := s.download(ctx, robotsURL, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &robotsRules{}
+		}
+		entry.rules = s.unavailableRobotsRules(robotsURL, err)
+		return entry.rules
+	}
+
+	entry.rules = parseRobots(resp.data, s.config.UserAgent)
+	if entry.rules.crawlDelay > 0 {
+		s.politeness.setHostDelay(u.Host, entry.rules.crawlDelay)
+	}
+	return entry.rules
+}
+
+// unavailableRobotsRules returns the rules to use for a host whose robots.txt
+// could not be downloaded. A missing file allows all URLs, if the server
+// failed or could not be reached all URLs are disallowed as the file might
+// exist.
+func (s *Scraper) unavailableRobotsRules(robotsURL *url.URL, err error) *robotsRules {
+	var statusErr *statusCodeError
+	if errors.As(err, &statusErr) && statusErr.code >= 400 && statusErr.code < 500 &&
+		statusErr.code != http.StatusTooManyRequests {
+
+		s.logger.Debug("robots.txt not available",
+			log.String("url", robotsURL.String()),
+			log.Err(err))
+		return &robotsRules{}
+	}
+
+	s.logger.Warn("Downloading robots.txt failed, disallowing all URLs of the host",
+		log.String("url", robotsURL.String()),
+		log.Err(err))
+	return &robotsRules{
+		rules: []robotsRule{newRobotsRule(false, "/")},
+	}
+}
+
+// isURLAllowedByRobots checks whether the robots.txt of the URL host allows
+// the URL to be crawled.
+func (s *Scraper) isURLAllowedByRobots(ctx context.Context, u *url.URL) bool {
+	if s.config.IgnoreRobotsTxt {
+		return true
+	}
+
+	rules := s.robotsRulesForURL(ctx, u)
+	allowed, rule := rules.allowed(u)
+	if rule == nil {
+		return true
+	}
+
+	if !allowed {
+		s.logger.Info("Skipping URL disallowed by robots.txt",
+			log.String("url", u.String()),
+			log.String("robots_rule", "Disallow: "+rule.pattern))
+		return false
+	}
+
+	s.logger.Debug("URL allowed by robots.txt",
+		log.String("url", u.String()),
+		log.String("robots_rule", "Allow: "+rule.pattern))
+	return true
+}
@@ -0,0 +1,168 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRobotsTxt = []byte(`
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+
+User-agent: goscrape
+User-agent: otherbot
+Disallow: /*.pdf$
+Disallow: /search?q=
+Allow: /search?q=allowed
+Crawl-delay: 1.5
+
+user-agent: GOSCRAPE
+disallow: /tmp
+
+Sitemap: https://example.org/sitemap.xml
+`)
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"", "/", true},
+		{"", "/private/", false},
+		{"", "/private/secret.html", false},
+		{"", "/private/public.html", true},
+		{"", "/doc.pdf", true},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/private/", true},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/doc.pdf", false},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/doc.pdf?download=1", true},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/dir/doc.pdf", false},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/search?q=test", false},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/search?q=allowed", true},
+		{"Mozilla/5.0 (compatible; goscrape/1.0)", "/tmp/file", false},
+		{"otherbot", "/tmp/file", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.userAgent+test.path, func(t *testing.T) {
+			rules := parseRobots(testRobotsTxt, test.userAgent)
+			u, err := url.Parse("https://example.org" + test.path)
+			require.NoError(t, err)
+
+			allowed, _ := rules.allowed(u)
+			assert.Equal(t, test.allowed, allowed)
+		})
+	}
+
+	rules := parseRobots(testRobotsTxt, "goscrape")
+	assert.Equal(t, 1500*time.Millisecond, rules.crawlDelay)
+	assert.Equal(t, []string{"https://example.org/sitemap.xml"}, rules.sitemaps)
+}
+
+func TestScraperRobots(t *testing.T) {
+	indexPage := []byte(`
+<html>
+<body>
+<a href="/page">page</a>
+<a href="/private/page">private</a>
+<img src="/private/image.png"/>
+</body>
+</html>
+`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                  indexPage,
+		fullURL + "/page":              empty,
+		fullURL + "/private/page":      empty,
+		fullURL + "/private/image.png": empty,
+		fullURL + "/robots.txt":        testRobotsTxt,
+	}
+
+	scraper := newTestScraper(t, fullURL+"/", urls)
+	downloaded := set.New[string]()
+	httpDownloader := scraper.httpDownloader
+	scraper.httpDownloader = func(ctx context.Context, u *url.URL, stream *streamTarget) (*httpResponse, error) {
+		downloaded.Add(u.Path)
+		return httpDownloader(ctx, u, stream)
+	}
+
+	ctx := context.Background()
+	err := scraper.Start(ctx)
+	require.NoError(t, err)
+
+	expected := set.NewFromSlice([]string{
+		"/",
+		"/page",
+		"/robots.txt",
+	})
+	assert.Equal(t, expected, downloaded)
+}
+
+func TestRobotsRulesUnavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		allowed bool
+	}{
+		{"missing", &statusCodeError{code: http.StatusNotFound}, true},
+		{"forbidden", &statusCodeError{code: http.StatusForbidden}, true},
+		{"too many requests", &statusCodeError{code: http.StatusTooManyRequests}, false},
+		{"server error", fmt.Errorf("%w: %w", errExhaustedRetries, &statusCodeError{code: http.StatusServiceUnavailable}), false},
+		{"network error", errors.New("connection refused"), false},
+	}
+
+	u, err := url.Parse("https://example.org/page")
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scraper := newTestScraperWithConfig(t, Config{URL: "https://example.org/"}, nil)
+			scraper.logger = log.NewNop() // the failed download gets logged as warning
+			scraper.httpDownloader = func(_ context.Context, _ *url.URL, _ *streamTarget) (*httpResponse, error) {
+				return nil, test.err
+			}
+
+			allowed, _ := scraper.robotsRulesForURL(context.Background(), u).allowed(u)
+			assert.Equal(t, test.allowed, allowed)
+		})
+	}
+}
+
+func TestRobotsRulesCanceled(t *testing.T) {
+	scraper := newTestScraperWithConfig(t, Config{URL: "https://example.org/"}, nil)
+	var downloads int
+	scraper.httpDownloader = func(ctx context.Context, _ *url.URL, _ *streamTarget) (*httpResponse, error) {
+		downloads++
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &httpResponse{data: []byte("User-agent: *\nDisallow: /private/\n")}, nil
+	}
+
+	u, err := url.Parse("https://example.org/private/page")
+	require.NoError(t, err)
+
+	// the result of a canceled download is not cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scraper.robotsRulesForURL(ctx, u)
+
+	allowed, _ := scraper.robotsRulesForURL(context.Background(), u).allowed(u)
+	assert.False(t, allowed)
+	scraper.robotsRulesForURL(context.Background(), u)
+	assert.Equal(t, 2, downloads)
+}
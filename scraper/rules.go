@@ -0,0 +1,374 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule fields that conditions can match on.
+const (
+	ruleFieldScheme = "scheme"
+	ruleFieldHost   = "host"
+	ruleFieldPath   = "path"
+	ruleFieldQuery  = "query"
+	ruleFieldDepth  = "depth"
+	ruleFieldSource = "source"
+	ruleFieldType   = "type"
+	ruleFieldSize   = "size"
+)
+
+// Sources of links that are not elements of pages.
+const (
+	sourceCSS     = "css"
+	sourceSitemap = "sitemap"
+)
+
+// ruleOperators contains the operators of rule conditions, operators that
+// are a prefix of another operator are listed after it.
+var ruleOperators = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+var (
+	errRuleAction    = errors.New("rule has to start with include or exclude")
+	errRuleCondition = errors.New("rule has no conditions")
+)
+
+// ruleTarget contains the properties of a URL that rules match on.
+type ruleTarget struct {
+	url    *url.URL
+	depth  uint   // number of links from the start page to the page
+	source string // tag of the element that linked the URL, like a or img
+
+	// the response properties are only known after the download
+	responseKnown bool
+	contentType   string // media type of the response
+	size          int64  // size of the response body in bytes
+}
+
+// ruleMatch is the result of matching a rule or condition against a target.
+type ruleMatch int
+
+const (
+	ruleNoMatch ruleMatch = iota
+	ruleMatches
+	ruleUnknown // the result depends on response properties that are not known yet
+)
+
+// rule includes or excludes all URLs that match all of its conditions.
+type rule struct {
+	text       string
+	include    bool
+	conditions []ruleCondition
+}
+
+// ruleCondition compares a field of a target with a value.
+type ruleCondition struct {
+	field    string
+	operator string
+	value    string
+	re       *regexp.Regexp // set for the ~ and !~ operators
+	number   int64          // set for numeric fields
+}
+
+// ruleSet is an ordered list of rules, the first matching rule decides.
+type ruleSet []*rule
+
+// ruleDecision is the result of checking a target against a rule set.
+type ruleDecision struct {
+	included bool
+	// final is false if a rule depends on response properties that are not
+	// known yet, the target has to be checked again after the download
+	final bool
+	rule  *rule // the rule that decided or has to be checked after the download
+}
+
+// parseRules parses a list of rules. A rule starts with include or exclude
+// followed by conditions separated by spaces like "exclude query~replytocom".
+// A condition consists of a field, an operator and a value without spaces.
+// The fields are scheme, host, path, query, depth, source, type and size.
+// The operators are = and != for equality, ~ and !~ for regular expressions
+// and <, <=, > and >= for the numeric fields depth and size. Host values
+// like *.example.com match all subdomains, type values like image/* match
+// all subtypes and size values can have a KB, MB or GB suffix.
+// The allowDepth parameter defines whether conditions on the depth can be used.
+func parseRules(rules []string, allowDepth bool) (ruleSet, error) {
+	var set ruleSet
+	var errs []error
+
+	for _, text := range rules {
+		r, err := parseRule(text, allowDepth)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing rule '%s': %w", text, err))
+			continue
+		}
+		set = append(set, r)
+	}
+
+	if errs != nil {
+		return nil, errors.Join(errs...)
+	}
+	return set, nil
+}
+
+func parseRule(text string, allowDepth bool) (*rule, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, errRuleAction
+	}
+
+	r := &rule{text: strings.Join(fields, " ")}
+	switch strings.ToLower(fields[0]) {
+	case "include":
+		r.include = true
+	case "exclude":
+	default:
+		return nil, errRuleAction
+	}
+
+	if len(fields) == 1 {
+		return nil, errRuleCondition
+	}
+
+	for _, field := range fields[1:] {
+		condition, err := parseRuleCondition(field)
+		if err != nil {
+			return nil, err
+		}
+		if condition.field == ruleFieldDepth && !allowDepth {
+			return nil, errors.New("depth conditions are only supported for pages")
+		}
+		r.conditions = append(r.conditions, condition)
+	}
+
+	return r, nil
+}
+
+func parseRuleCondition(text string) (ruleCondition, error) {
+	i := strings.IndexAny(text, "!~<>=")
+	if i <= 0 {
+		return ruleCondition{}, fmt.Errorf("condition '%s' has no field or operator", text)
+	}
+
+	c := ruleCondition{field: strings.ToLower(text[:i])}
+	for _, operator := range ruleOperators {
+		if value, ok := strings.CutPrefix(text[i:], operator); ok {
+			c.operator = operator
+			c.value = value
+			break
+		}
+	}
+	if c.operator == "" {
+		return ruleCondition{}, fmt.Errorf("condition '%s' has an invalid operator", text)
+	}
+
+	numeric := false
+	switch c.field {
+	case ruleFieldScheme, ruleFieldHost, ruleFieldPath, ruleFieldQuery, ruleFieldSource, ruleFieldType:
+	case ruleFieldDepth, ruleFieldSize:
+		numeric = true
+	default:
+		return ruleCondition{}, fmt.Errorf("condition '%s' has an unknown field", text)
+	}
+
+	var err error
+	switch c.operator {
+	case "~", "!~":
+		if numeric {
+			return ruleCondition{}, fmt.Errorf("condition '%s' uses a regular expression for a number", text)
+		}
+		if c.re, err = regexp.Compile(c.value); err != nil {
+			return ruleCondition{}, fmt.Errorf("compiling regular expression of condition '%s': %w", text, err)
+		}
+	case "<", "<=", ">", ">=":
+		if !numeric {
+			return ruleCondition{}, fmt.Errorf("condition '%s' compares a text field numerically", text)
+		}
+	}
+
+	if numeric {
+		if c.number, err = parseRuleNumber(c.value); err != nil {
+			return ruleCondition{}, fmt.Errorf("parsing number of condition '%s': %w", text, err)
+		}
+	}
+
+	switch c.field {
+	case ruleFieldHost, ruleFieldType, ruleFieldScheme, ruleFieldSource:
+		if c.re == nil {
+			c.value = strings.ToLower(c.value)
+		}
+	}
+
+	return c, nil
+}
+
+// appendPathRules appends rules for the include and exclude expressions that
+// match the URL path, the exclude rules are checked first.
+func appendPathRules(rules ruleSet, includes, excludes []*regexp.Regexp) ruleSet {
+	for _, re := range excludes {
+		rules = append(rules, pathRule(re, false))
+	}
+	for _, re := range includes {
+		rules = append(rules, pathRule(re, true))
+	}
+	return rules
+}
+
+func pathRule(re *regexp.Regexp, include bool) *rule {
+	action := "exclude"
+	if include {
+		action = "include"
+	}
+	return &rule{
+		text:    action + " " + ruleFieldPath + "~" + re.String(),
+		include: include,
+		conditions: []ruleCondition{
+			{field: ruleFieldPath, operator: "~", value: re.String(), re: re},
+		},
+	}
+}
+
+// parseRuleNumber parses a number with an optional KB, MB or GB suffix.
+func parseRuleNumber(value string) (int64, error) {
+	multiplier := int64(1)
+	upper := strings.ToUpper(value)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if number, ok := strings.CutSuffix(upper, suffix); ok {
+			upper = number
+			multiplier = m
+			break
+		}
+	}
+
+	number, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing number: %w", err)
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("negative number %d", number)
+	}
+	return number * multiplier, nil
+}
+
+// decide returns whether the target is included by the rule set. The first
+// matching rule decides, if no rule matches the target is included unless
+// the set contains include rules.
+func (rs ruleSet) decide(target ruleTarget) ruleDecision {
+	for _, r := range rs {
+		switch r.match(target) {
+		case ruleMatches:
+			return ruleDecision{included: r.include, final: true, rule: r}
+		case ruleUnknown:
+			// the rule can only be checked after the download, later
+			// rules can not decide before
+			return ruleDecision{included: true, rule: r}
+		case ruleNoMatch:
+		}
+	}
+
+	for _, r := range rs {
+		if r.include {
+			return ruleDecision{included: false, final: true}
+		}
+	}
+	return ruleDecision{included: true, final: true}
+}
+
+// match returns whether all conditions of the rule match the target.
+func (r *rule) match(target ruleTarget) ruleMatch {
+	result := ruleMatches
+	for _, c := range r.conditions {
+		switch c.match(target) {
+		case ruleNoMatch:
+			return ruleNoMatch
+		case ruleUnknown:
+			result = ruleUnknown
+		case ruleMatches:
+		}
+	}
+	return result
+}
+
+func (c ruleCondition) match(target ruleTarget) ruleMatch {
+	var value string
+	var number int64
+
+	switch c.field {
+	case ruleFieldScheme:
+		value = strings.ToLower(target.url.Scheme)
+	case ruleFieldHost:
+		value = hostname(target.url.Host)
+	case ruleFieldPath:
+		value = target.url.Path
+	case ruleFieldQuery:
+		value = target.url.RawQuery
+	case ruleFieldSource:
+		value = strings.ToLower(target.source)
+	case ruleFieldDepth:
+		number = int64(target.depth)
+	case ruleFieldType:
+		if !target.responseKnown {
+			return ruleUnknown
+		}
+		value = target.contentType
+	case ruleFieldSize:
+		if !target.responseKnown {
+			return ruleUnknown
+		}
+		number = target.size
+	}
+
+	var matches bool
+	switch c.operator {
+	case "=", "!=":
+		matches = c.equals(value, number)
+		if c.operator == "!=" {
+			matches = !matches
+		}
+	case "~":
+		matches = c.re.MatchString(value)
+	case "!~":
+		matches = !c.re.MatchString(value)
+	case "<":
+		matches = number < c.number
+	case "<=":
+		matches = number <= c.number
+	case ">":
+		matches = number > c.number
+	case ">=":
+		matches = number >= c.number
+	}
+
+	if matches {
+		return ruleMatches
+	}
+	return ruleNoMatch
+}
+
+// equals returns whether the value equals the value of the condition.
+func (c ruleCondition) equals(value string, number int64) bool {
+	switch c.field {
+	case ruleFieldDepth, ruleFieldSize:
+		return number == c.number
+	case ruleFieldHost:
+		return matchesDomain(c.value, value)
+	case ruleFieldType:
+		matched, _ := path.Match(c.value, value)
+		return matched
+	default:
+		return value == c.value
+	}
+}
+
+// mediaType returns the lowercase media type of a Content-Type header value.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules([]string{
+		"exclude query~replytocom=",
+		"Include host=*.example.org  path=/docs depth<=2",
+		"exclude type=image/* size>1MB",
+	}, true)
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.False(t, rules[0].include)
+	assert.True(t, rules[1].include)
+	assert.Equal(t, "Include host=*.example.org path=/docs depth<=2", rules[1].text)
+	require.Len(t, rules[2].conditions, 2)
+	assert.Equal(t, int64(1<<20), rules[2].conditions[1].number)
+
+	invalid := []string{
+		"",
+		"skip path=/",
+		"exclude",
+		"exclude path",
+		"exclude name=value",
+		"exclude path<5",
+		"exclude size~1",
+		"exclude size>big",
+		"exclude path~(",
+	}
+	for _, text := range invalid {
+		_, err := parseRules([]string{text}, true)
+		assert.Error(t, err, text)
+	}
+
+	_, err = parseRules([]string{"exclude depth>1"}, false)
+	assert.Error(t, err)
+}
+
+func TestRuleSetDecide(t *testing.T) {
+	rules, err := parseRules([]string{
+		"exclude query~replytocom=",
+		"exclude host=ads.example.org",
+		"exclude source=video",
+		"include path~^/docs/ depth<=2",
+		"exclude type=application/zip size>=1KB",
+		"include scheme=https",
+	}, true)
+	require.NoError(t, err)
+
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return u
+	}
+
+	tests := []struct {
+		target   ruleTarget
+		included bool
+		final    bool
+	}{
+		{ruleTarget{url: parse("https://example.org/post?replytocom=5")}, false, true},
+		{ruleTarget{url: parse("https://www.ads.example.org/")}, false, true},
+		{ruleTarget{url: parse("https://example.org/movie.mp4"), source: "video"}, false, true},
+		{ruleTarget{url: parse("http://example.org/docs/intro"), depth: 2}, true, true},
+		{ruleTarget{url: parse("http://example.org/docs/intro"), depth: 3}, true, false},
+		{ruleTarget{url: parse("http://example.org/file.zip"), responseKnown: true, contentType: "application/zip", size: 1024}, false, true},
+		{ruleTarget{url: parse("http://example.org/file.zip"), responseKnown: true, contentType: "application/zip", size: 10}, false, true},
+		{ruleTarget{url: parse("https://example.org/file.zip"), responseKnown: true, contentType: "application/zip", size: 10}, true, true},
+	}
+
+	for _, test := range tests {
+		decision := rules.decide(test.target)
+		assert.Equal(t, test.included, decision.included, test.target.url.String())
+		assert.Equal(t, test.final, decision.final, test.target.url.String())
+	}
+}
+
+func TestScraperRules(t *testing.T) {
+	indexPage := []byte(`<html><body>
+<a href="/post">Post</a>
+<a href="/post?replytocom=1">Reply</a>
+<a href="/files/archive.zip">Archive</a>
+<img src="/img/small.png">
+<video src="/movie.mp4"></video>
+</body></html>`)
+	empty := []byte(`<html></html>`)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                  indexPage,
+		fullURL + "/post":              empty,
+		fullURL + "/post?replytocom=1": empty,
+		fullURL + "/files/archive.zip": []byte("PK\x03\x04 zip content"),
+		fullURL + "/img/small.png":     []byte("\x89PNG\r\n\x1a\n"),
+		fullURL + "/movie.mp4":         empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:             fullURL + "/",
+		Storage:         files,
+		IgnoreRobotsTxt: true,
+		PageRules:       []string{"exclude query~replytocom=", "exclude type=application/zip"},
+		AssetRules:      []string{"exclude source=video"},
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/post",
+		"/post?replytocom=1",
+		"/files/archive.zip",
+		"/img/small.png",
+		"/movie.mp4",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	expected := []string{
+		"example.org/img/small.png",
+		"example.org/index.html",
+		"example.org/post.html",
+	}
+	assert.Equal(t, expected, files.Paths())
+}
+
+func TestScraperExplain(t *testing.T) {
+	cfg := Config{
+		URL:          "https://example.org/",
+		Excludes:     []string{"^/private"},
+		PageRules:    []string{"exclude query~replytocom="},
+		AssetRules:   []string{"exclude size>1MB"},
+		AssetDomains: []string{"cdn.example.org"},
+	}
+	scraper, err := New(log.NewNop(), cfg)
+	require.NoError(t, err)
+
+	explain := func(s string) []string {
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return scraper.Explain(u)
+	}
+
+	assert.Equal(t, []string{
+		"page: excluded by rule 'exclude query~replytocom='",
+		"asset: decided after the download by rule 'exclude size>1MB' and the following rules",
+	}, explain("https://example.org/post?replytocom=1"))
+	assert.Equal(t, []string{
+		"page: excluded by rule 'exclude path~^/private'",
+		"asset: decided after the download by rule 'exclude size>1MB' and the following rules",
+	}, explain("https://example.org/private/file"))
+	assert.Equal(t, []string{
+		"page: excluded, the host other.org is not a page domain",
+		"asset: excluded, the host other.org is not an asset domain",
+	}, explain("https://other.org/"))
+	assert.Equal(t, []string{
+		"page: included, no rule matched",
+		"asset: decided after the download by rule 'exclude size>1MB' and the following rules",
+	}, explain("https://example.org/about"))
+}
@@ -10,14 +10,17 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cornelk/goscrape/har"
 	"github.com/cornelk/goscrape/htmlindex"
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/goscrape/warc"
 	"github.com/cornelk/gotokit/httpclient"
 	"github.com/cornelk/gotokit/log"
 	"github.com/cornelk/gotokit/set"
-	"github.com/h2non/filetype"
-	"github.com/h2non/filetype/types"
 	"golang.org/x/net/html"
 )
 
@@ -27,10 +30,31 @@ type Config struct {
 	Includes []string
 	Excludes []string
 
+	// rules that decide which pages and assets are downloaded, they are
+	// checked before the include and exclude expressions, see parseRules
+	// for the syntax
+	PageRules  []string
+	AssetRules []string
+
+	// IgnoredQueryParameters contains query parameters like session IDs that
+	// are ignored for file names and duplicate detection, a name that ends
+	// with * matches all parameters with that prefix like utm_*.
+	IgnoredQueryParameters []string
+
+	Concurrency  uint // number of concurrent downloads, 0 or 1 to download sequentially
 	ImageQuality uint // image quality from 0 to 100%, 0 to disable reencoding
 	MaxDepth     uint // download depth, 0 for unlimited
 	Timeout      uint // time limit in seconds to process each http request
 
+	// limits of the crawl, 0 for unlimited. The crawl stops once the number
+	// of pages, assets, total bytes or the duration is reached, responses
+	// larger than the maximum response size are skipped.
+	MaxResponseSize int64         // maximum size of a response body in bytes
+	MaxPages        uint          // maximum number of pages to download
+	MaxAssets       uint          // maximum number of assets to download
+	MaxTotalSize    int64         // maximum number of response body bytes to download
+	MaxDuration     time.Duration // maximum duration of the crawl
+
 	OutputDirectory string
 	Username        string
 	Password        string
@@ -39,14 +63,50 @@ type Config struct {
 	Header    http.Header
 	Proxy     string
 	UserAgent string
+
+	// politeness settings that are applied per host to pages and assets
+	RequestsPerSecond     float64       // maximum requests per second, 0 for unlimited
+	CrawlDelay            time.Duration // minimum delay between requests
+	CrawlDelayJitter      time.Duration // maximum random delay added to the crawl delay
+	MaxConnectionsPerHost uint          // maximum concurrent requests, 0 for unlimited
+
+	// retry policy of failed requests, the delay before a retry doubles on
+	// each retry up to the maximum delay or is set by a Retry-After header
+	MaxAttempts        uint          // maximum number of attempts of a request, 0 for the default of 10, 1 to disable retries
+	RetryDelay         time.Duration // delay before the first retry, 0 for the default of 1 second
+	RetryMaxDelay      time.Duration // maximum delay between two attempts, 0 for the default of 1 minute
+	RetryJitter        float64       // fraction of the delay that is randomized, from 0 to 1
+	RetryStatusCodes   []int         // response status codes to retry, nil for 408, 429, 500, 502, 503 and 504
+	RetryNetworkErrors bool          // retry connection errors, timeouts and interrupted responses
+
+	AcceptedStatusCodes []int // response status codes besides 200 whose body is processed, nil for 203
+	ErrorPage           bool  // store the first 404 or 410 page of the main host as error page of the website
+
+	IgnoreRobotsTxt bool // do not fetch and honour robots.txt files
+
+	// domain policy, a domain like example.com matches its www equivalent as
+	// well and a domain like *.example.com matches all of its subdomains
+	PageDomains          []string // domains to download pages from besides the host of the URL
+	AssetDomains         []string // domains to download assets from, all domains if empty
+	ExcludedAssetDomains []string // domains to not download assets from
+	SpanHostsDepth       uint     // number of links to follow to pages of other domains, 0 to disable
+
+	Sitemaps         []string // sitemap URLs to seed the crawl with
+	DiscoverSitemaps bool     // seed the crawl with the sitemaps listed in robots.txt
+
+	Resume      bool // continue an interrupted crawl from the persisted crawl state
+	Incremental bool // revalidate files of a previous scrape using conditional requests
+
+	WARCDirectory   string // directory to write WARC files of all HTTP exchanges to, empty to disable
+	WARCMaxFileSize int64  // size in bytes after which a new WARC file is started, 0 for the default
+	WARCOnly        bool   // only write WARC files and no browsable directory output
+
+	HARFile string // file to write all HTTP requests and responses to in HAR format, empty to disable
+
+	Storage storage.Storage // storage to write the files to, the local file system if nil
 }
 
-type (
-	httpDownloader     func(ctx context.Context, u *url.URL) ([]byte, *url.URL, error)
-	dirCreator         func(path string) error
-	fileExistenceCheck func(filePath string) bool
-	fileWriter         func(filePath string, data []byte) error
-)
+type httpDownloader func(ctx context.Context, u *url.URL, stream *streamTarget) (*httpResponse, error)
 
 // Scraper contains all scraping data.
 type Scraper struct {
@@ -55,23 +115,51 @@ type Scraper struct {
 	logger  *log.Logger
 	URL     *url.URL // contains the main URL to parse, will be modified in case of a redirect
 
-	auth   string
-	client *http.Client
+	auth       string
+	client     *http.Client
+	politeness *politeness
+	retry      retryPolicy
 
-	includes []*regexp.Regexp
-	excludes []*regexp.Regexp
+	acceptedStatus  set.Set[int] // response status codes whose body is processed
+	errorPageStored atomic.Bool
 
+	pageRules  ruleSet
+	assetRules ruleSet
+	query      queryFilter
+	domains    domainPolicy
+	limits     *crawlLimits
+	report     *crawlReport
+
+	// mu protects the processed set, the asset queue and the host spans
+	// which are accessed by concurrent download workers.
+	mu sync.Mutex
 	// key is the URL of page or asset
 	processed set.Set[string]
-
-	imagesQueue       []*url.URL
+	// key is the scheme and host of the robots.txt file
+	robots map[string]*robotsEntry
+	// key is the URL of a page or asset whose rules can only be checked
+	// after the download
+	pendingRules map[string]ruleTarget
+	// key is the URL of a processed page or asset
+	results  map[string]urlResult
+	state    *stateStore
+	metadata *metadataStore // nil if incremental scraping is disabled
+	warc     *warc.Writer   // nil if WARC output is disabled
+	har      *har.Recorder  // nil if HAR output is disabled
+
+	assetQueue        []assetReference
 	webPageQueue      []*url.URL
 	webPageQueueDepth map[string]uint
+	// key is the URL of a queued page or the final URL of a redirected page,
+	// value is the number of links that lead to the page from a page of the
+	// page domains
+	webPageHostSpan map[string]uint
 
-	dirCreator         dirCreator
-	fileExistenceCheck fileExistenceCheck
-	fileWriter         fileWriter
-	httpDownloader     httpDownloader
+	// downloadSlots limits the number of concurrent HTTP downloads
+	downloadSlots chan struct{}
+
+	storage        storage.Storage
+	httpDownloader httpDownloader
 }
 
 // New creates a new Scraper instance.
@@ -95,6 +183,20 @@ func New(logger *log.Logger, cfg Config) (*Scraper, error) {
 		errs = append(errs, err)
 	}
 
+	pageRules, err := parseRules(cfg.PageRules, true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("parsing page rules: %w", err))
+	}
+
+	assetRules, err := parseRules(cfg.AssetRules, false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("parsing asset rules: %w", err))
+	}
+
+	if err := validateRetryConfig(cfg); err != nil {
+		errs = append(errs, err)
+	}
+
 	if errs != nil {
 		return nil, errors.Join(errs...)
 	}
@@ -114,10 +216,22 @@ func New(logger *log.Logger, cfg Config) (*Scraper, error) {
 		return nil, fmt.Errorf("creating proxy transport: %w", err)
 	}
 
+	var roundTripper http.RoundTripper = transport
+	var warcWriter *warc.Writer
+	if cfg.WARCDirectory != "" {
+		warcWriter = newWARCWriter(cfg, u)
+		roundTripper = &redirectArchiver{transport: roundTripper, warc: warcWriter, logger: logger}
+	}
+	var recorder *har.Recorder
+	if cfg.HARFile != "" {
+		recorder = newHARRecorder(roundTripper)
+		roundTripper = recorder
+	}
+
 	client := &http.Client{
 		Jar:       cookies,
 		Timeout:   time.Duration(cfg.Timeout) * time.Second,
-		Transport: transport,
+		Transport: roundTripper,
 	}
 
 	s := &Scraper{
@@ -126,19 +240,41 @@ func New(logger *log.Logger, cfg Config) (*Scraper, error) {
 		logger:  logger,
 		URL:     u,
 
-		client: client,
-
-		includes: includes,
-		excludes: excludes,
-
-		processed: set.New[string](),
+		client:     client,
+		warc:       warcWriter,
+		har:        recorder,
+		politeness: newPoliteness(cfg),
+		retry:      newRetryPolicy(cfg),
+
+		acceptedStatus: newAcceptedStatusCodes(cfg),
+
+		pageRules:  appendPathRules(pageRules, includes, excludes),
+		assetRules: appendPathRules(assetRules, includes, excludes),
+		query:      queryFilter{ignored: cfg.IgnoredQueryParameters},
+		domains:    newDomainPolicy(cfg),
+		limits:     newCrawlLimits(cfg),
+		report:     newCrawlReport(),
+
+		processed:    set.New[string](),
+		robots:       map[string]*robotsEntry{},
+		pendingRules: map[string]ruleTarget{},
+		results:      map[string]urlResult{},
+		state: &stateStore{
+			filePath: stateFilePath(cfg.OutputDirectory, u),
+		},
 
 		webPageQueueDepth: map[string]uint{},
+		webPageHostSpan:   map[string]uint{},
+	}
+	s.downloadSlots = make(chan struct{}, s.workerCount())
+	if cfg.Incremental {
+		s.metadata = newMetadataStore(cfg.OutputDirectory, u)
 	}
 
-	s.dirCreator = s.createDownloadPath
-	s.fileExistenceCheck = s.fileExists
-	s.fileWriter = s.writeFile
+	s.storage = cfg.Storage
+	if s.storage == nil {
+		s.storage = storage.NewFileSystem()
+	}
 	s.httpDownloader = s.downloadURLWithRetries
 
 	if s.config.Username != "" {
@@ -148,128 +284,388 @@ func New(logger *log.Logger, cfg Config) (*Scraper, error) {
 	return s, nil
 }
 
-// Start starts the scraping.
+// Start starts the scraping. If a limit of the crawl is reached, the crawl
+// is stopped and no error is returned.
 func (s *Scraper) Start(ctx context.Context) error {
-	if err := s.dirCreator(s.config.OutputDirectory); err != nil {
+	if err := s.metadata.load(s.storage); err != nil {
 		return err
 	}
+	defer s.saveMetadata()
+	defer s.closeWARC()
+	defer s.writeHAR()
+
+	ctx, stop := s.startLimits(ctx)
+	defer stop()
 
-	if !s.shouldURLBeDownloaded(s.URL, 0, false) {
+	s.report.start()
+	defer s.report.finish()
+
+	if err := s.crawl(ctx); err != nil {
+		if s.reachedLimit() != nil && errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+	if s.reachedLimit() == nil {
+		s.removeState()
+	}
+	return nil
+}
+
+// crawl processes the start page or the resumed crawl state and all pages
+// of the queue.
+func (s *Scraper) crawl(ctx context.Context) error {
+	resumed := false
+	if s.config.Resume {
+		var err error
+		if resumed, err = s.loadState(); err != nil {
+			return err
+		}
+	}
+
+	if !resumed {
+		if err := s.processStartPage(ctx); err != nil {
+			return err
+		}
+		s.saveStateLogged(true)
+	}
+
+	for len(s.webPageQueue) > 0 {
+		if err := s.processQueue(ctx); err != nil {
+			s.saveStateLogged(true)
+			return err
+		}
+		s.saveStateLogged(true)
+		s.saveMetadata()
+	}
+
+	return nil
+}
+
+// processStartPage processes the start page and seeds the queue with its
+// links and the pages listed in the sitemaps.
+func (s *Scraper) processStartPage(ctx context.Context) error {
+	if !s.shouldURLBeDownloaded(ctx, ruleTarget{url: s.URL}, false) {
 		return errors.New("start page is excluded from downloading")
 	}
 
-	if err := s.processURL(ctx, s.URL, 0); err != nil {
+	startURL := s.URL
+	references, err := s.processURL(ctx, startURL, 0)
+	if err != nil {
 		return err
 	}
+	s.recordResult(startURL, nil, nil)
+	s.enqueuePages(ctx, references, 0, 0)
+
+	return s.seedFromSitemaps(ctx)
+}
+
+// processQueue processes all pages that are currently in the queue using the
+// worker pool. Found page links are added to the queue in the order of the
+// processed pages to keep the crawl order independent of the download timing.
+// The queue is kept unchanged while its pages are processed so that it can
+// be persisted in the crawl state.
+func (s *Scraper) processQueue(ctx context.Context) error {
+	queue := s.webPageQueue
+
+	pageReferences := make([][]pageLink, len(queue))
+	err := s.forEach(ctx, len(queue), func(i int) error {
+		ur := queue[i]
+		if references, ok := s.processedPageLinks(ur); ok { // processed before resuming
+			pageReferences[i] = references
+			return nil
+		}
 
-	for len(s.webPageQueue) > 0 {
-		ur := s.webPageQueue[0]
-		s.webPageQueue = s.webPageQueue[1:]
 		currentDepth := s.webPageQueueDepth[ur.String()]
-		if err := s.processURL(ctx, ur, currentDepth+1); err != nil && errors.Is(err, context.Canceled) {
+		references, err := s.processURL(ctx, ur, currentDepth+1)
+		if err != nil && errors.Is(err, context.Canceled) {
 			return err
 		}
+		pageReferences[i] = references
+
+		s.recordResult(ur, err, references)
+		s.saveStateLogged(false)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	s.webPageQueue = nil
+	for i, ur := range queue {
+		currentDepth := s.webPageQueueDepth[ur.String()]
+		s.enqueuePages(ctx, pageReferences[i], currentDepth+1, s.webPageHostSpan[ur.String()])
+	}
+	s.clearResultLinks(queue)
 	return nil
 }
 
-func (s *Scraper) processURL(ctx context.Context, u *url.URL, currentDepth uint) error {
+// pageLink is a link to a page and the tag of the element that linked it.
+type pageLink struct {
+	url    *url.URL
+	source string
+}
+
+// enqueuePages adds all page references that should be downloaded to the
+// queue of web pages to process. The parent span is the host span of the
+// page that contains the references.
+func (s *Scraper) enqueuePages(ctx context.Context, references []pageLink, currentDepth, parentSpan uint) {
+	for _, link := range references {
+		ur := link.url
+		ur.Fragment = ""
+
+		hostSpan, ok := s.domains.hostSpan(s.URL.Host, ur.Host, parentSpan)
+		if !ok {
+			if s.markProcessed(s.processedKey(ur)) {
+				s.logger.Debug("Skipping external host page", log.String("url", ur.String()))
+			}
+			continue
+		}
+
+		// the depth of the start page is 0 and its links are queued with
+		// a current depth of 0 as well
+		target := ruleTarget{url: ur, depth: currentDepth + 1, source: link.source}
+		if s.shouldURLBeDownloaded(ctx, target, false) {
+			s.webPageQueue = append(s.webPageQueue, ur)
+			s.webPageQueueDepth[ur.String()] = currentDepth
+			s.mu.Lock()
+			s.webPageHostSpan[ur.String()] = hostSpan
+			s.mu.Unlock()
+		}
+	}
+}
+
+// setRedirectHostSpan sets the host span of the final URL of a redirect,
+// the redirect counts like a link from the requested URL. The links of the
+// final page are relinked and queued based on its span, the span is stored
+// for the requested URL as well as the queue only knows the requested URL.
+func (s *Scraper) setRedirectHostSpan(requested, final *url.URL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := s.webPageHostSpan[requested.String()]
+	if final.Host != requested.Host {
+		span, _ = s.domains.hostSpan(s.URL.Host, final.Host, span)
+	}
+	s.webPageHostSpan[final.String()] = span
+	s.webPageHostSpan[requested.String()] = span
+}
+
+// processURL downloads and stores a page and its assets and returns the
+// hyperlinks that were found in the page.
+func (s *Scraper) processURL(ctx context.Context, u *url.URL, currentDepth uint) ([]pageLink, error) {
+	if err := s.reserveDownload(ctx, false); err != nil {
+		return nil, err
+	}
+
+	// the start page can redirect to a new base URL that its file path
+	// depends on, it is read into memory
+	var stream *streamTarget
+	if currentDepth > 0 {
+		stream = s.documentStreamTarget(u)
+	}
+
 	s.logger.Info("Downloading webpage", log.String("url", u.String()))
-	data, respURL, err := s.httpDownloader(ctx, u)
+	started := time.Now()
+	resp, err := s.download(ctx, u, stream)
+	s.report.addDownload(u, ReportTypePage, resp, err, time.Since(started))
 	if err != nil {
-		s.logger.Error("Processing HTTP Request failed",
-			log.String("url", u.String()),
-			log.Err(err))
-		return err
+		if !errors.Is(err, context.Canceled) { // stopped crawls are logged once
+			s.logger.Error("Processing HTTP Request failed",
+				log.String("url", u.String()),
+				log.Err(err))
+			s.storeErrorPage(ctx, err)
+		}
+		return nil, err
+	}
+	data := resp.data
+	requestURL := u
+
+	// the rules are checked for the requested URL that they were queued for,
+	// before a redirect stub is written for it
+	if !resp.notModified &&
+		!s.isResponseAllowedByRules(requestURL, resp.contentType, resp.bodyStart(), resp.bodySize(), false) {
+		s.discardFile(resp)
+		return nil, nil
+	}
+
+	if currentDepth > 0 {
+		// the content of a redirected page is stored once under the final
+		// URL, the requested URL gets a stub that forwards to it
+		if final := s.redirectedURL(u, resp.url); final != u {
+			s.writeRedirectStub(u, final)
+			if !s.markProcessed(s.processedKey(final)) {
+				s.logger.Debug("Skipping already processed redirect target",
+					log.String("url", u.String()),
+					log.String("target", final.String()))
+				s.discardFile(resp)
+				return nil, nil
+			}
+			s.setRedirectHostSpan(u, final)
+			u = final
+		}
 	}
 
-	fileExtension := ""
-	kind, err := filetype.Match(data)
-	if err == nil && kind != types.Unknown {
-		fileExtension = kind.Extension
+	if resp.file != nil {
+		// documents like videos or archives contain no page links
+		if filePath := s.commitFile(u, resp); filePath != "" {
+			s.metadata.setFilePath(requestURL, filePath)
+			s.writeDocumentStub(u, filePath)
+		}
+		return nil, nil
 	}
 
+	if resp.notModified {
+		s.logger.Info("Webpage not modified", log.String("url", u.String()))
+		var ok bool
+		if data, ok = s.cachedPage(requestURL); !ok {
+			return nil, nil // binary file that contains no links
+		}
+	}
+
+	content := detectContent(resp.contentType, data)
+
 	if currentDepth == 0 {
-		u = respURL
+		u = resp.url
 		// use the URL that the website returned as new base url for the
 		// scrape, in case of a redirect it changed
 		s.URL = u
 	}
 
+	if !content.isPage {
+		// documents like images, JSON or XML files contain no page links
+		if !resp.notModified {
+			if filePath := s.storeFile(u, data, content.extension); filePath != "" {
+				s.metadata.setFilePath(requestURL, filePath)
+				s.writeDocumentStub(u, filePath)
+			}
+		}
+		return nil, nil
+	}
+
+	if content.charset != "" && content.charset != "utf-8" {
+		s.logger.Debug("Page is not UTF-8 encoded, links are parsed assuming an ASCII compatible charset",
+			log.String("url", u.String()),
+			log.String("charset", content.charset))
+	}
+
 	buf := bytes.NewBuffer(data)
 	doc, err := html.Parse(buf)
 	if err != nil {
 		s.logger.Error("Parsing HTML failed",
 			log.String("url", u.String()),
 			log.Err(err))
-		return fmt.Errorf("parsing HTML: %w", err)
+		return nil, fmt.Errorf("parsing HTML: %w", err)
 	}
 
 	index := htmlindex.New(s.logger)
 	index.Index(u, doc)
 
-	s.storeDownload(u, data, doc, index, fileExtension)
+	if !resp.notModified {
+		if filePath := s.storePage(u, data, doc, index); filePath != "" {
+			s.metadata.setFilePath(requestURL, filePath)
+			s.storePageCache(requestURL, data)
+		}
+	}
 
-	if err := s.downloadReferences(ctx, index); err != nil {
-		return err
+	if err := s.downloadReferences(ctx, u, index); err != nil {
+		return nil, err
 	}
 
 	// check first and download afterward to not hit max depth limit for
 	// start page links because of recursive linking
-	// a hrefs
-	references, err := index.URLs(htmlindex.ATag)
+	// a hrefs, iframes, meta refreshes and links like canonical or next
+	var references []pageLink
+	for _, tag := range []string{htmlindex.ATag, htmlindex.IframeTag, htmlindex.MetaTag} {
+		urls, err := index.URLs(tag)
+		if err != nil {
+			s.logger.Error("Parsing URL failed", log.Err(err))
+		}
+		references = appendPageLinks(references, tag, urls)
+	}
+	urls, err := index.LinkURLs(htmlindex.LinkRelationPage)
 	if err != nil {
 		s.logger.Error("Parsing URL failed", log.Err(err))
 	}
+	references = appendPageLinks(references, htmlindex.LinkTag, urls)
 
-	for _, ur := range references {
-		ur.Fragment = ""
+	for _, link := range references {
+		s.report.addReferrer(link.url, u)
+	}
+	return references, nil
+}
 
-		if s.shouldURLBeDownloaded(ur, currentDepth, false) {
-			s.webPageQueue = append(s.webPageQueue, ur)
-			s.webPageQueueDepth[ur.String()] = currentDepth
-		}
+// documentStreamTarget returns the stream target for the download of a page
+// URL. Documents that are no pages like videos or archives are streamed to
+// their file path. Pages and documents with a missing or generic Content-Type
+// that has to be detected from the data are read into memory.
+func (s *Scraper) documentStreamTarget(u *url.URL) *streamTarget {
+	return &streamTarget{
+		filePath: func(final *url.URL, contentType string) string {
+			content := detectContent(contentType, nil)
+			if content.isPage {
+				return ""
+			}
+			return s.getFileFilePath(s.redirectedURL(u, final), content.extension)
+		},
+		// the extension of a URL without one depends on the Content-Type,
+		// only documents whose URL path has an extension can be resumed
+		resumePath: s.getFileFilePath(u, ""),
 	}
+}
 
-	return nil
+// appendPageLinks appends links for the given URLs to the list of links.
+func appendPageLinks(links []pageLink, source string, urls []*url.URL) []pageLink {
+	for _, u := range urls {
+		links = append(links, pageLink{url: u, source: source})
+	}
+	return links
 }
 
-// storeDownload writes the download to a file, if a known binary file is detected,
-// processing of the file as page to look for links is skipped.
-func (s *Scraper) storeDownload(u *url.URL, data []byte, doc *html.Node,
-	index *htmlindex.Index, fileExtension string) {
-
-	// We need to distinguish between HTML pages and binary files (images, PDFs, etc.)
-	// because they need different file path handling:
-	// - HTML pages: add .html extension, handle directory indexes like /about -> /about.html
-	// - Binary files: keep original path, so /photo.jpg stays /photo.jpg, not /photo.jpg.html
-	// This prevents breaking binary downloads that were working before.
-	isAPage := false
-	if fileExtension == "" {
-		fixed, hasChanges, err := s.fixURLReferences(u, doc, index)
-		if err != nil {
-			s.logger.Error("Fixing file references failed",
-				log.String("url", u.String()),
-				log.Err(err))
-			return
-		}
+// storePage fixes the references of a page to point to the downloaded files
+// and writes it to a file. Pages get the .html extension and directory indexes
+// like /about are stored as /about.html. It returns the path of the written
+// file or an empty string on failure.
+func (s *Scraper) storePage(u *url.URL, data []byte, doc *html.Node, index *htmlindex.Index) string {
+	fixed, hasChanges, err := s.fixURLReferences(u, doc, index)
+	if err != nil {
+		s.logger.Error("Fixing file references failed",
+			log.String("url", u.String()),
+			log.Err(err))
+		return ""
+	}
 
-		if hasChanges {
-			data = fixed
-		}
-		// Only HTML content gets processed as a "page" - binary files stay as-is
-		isAPage = true
+	if hasChanges {
+		data = fixed
 	}
 
-	filePath := s.getFilePath(u, isAPage)
 	// always update html files, content might have changed
-	if err := s.fileWriter(filePath, data); err != nil {
+	return s.storeDownload(u, data, s.getFilePath(u, true))
+}
+
+// storeFile writes a document that is not a page unmodified to a file. The
+// original path is kept, so /photo.jpg stays /photo.jpg, the file extension
+// of the content type is only appended if the path has none. It returns the
+// path of the written file or an empty string on failure.
+func (s *Scraper) storeFile(u *url.URL, data []byte, extension string) string {
+	return s.storeDownload(u, data, s.getFileFilePath(u, extension))
+}
+
+// storeDownload writes the download to a file and returns the path of the
+// written file or an empty string on failure.
+func (s *Scraper) storeDownload(u *url.URL, data []byte, filePath string) string {
+	if s.config.WARCOnly {
+		return ""
+	}
+
+	if err := s.storage.WriteFile(filePath, data); err != nil {
 		s.logger.Error("Writing to file failed",
 			log.String("URL", u.String()),
 			log.String("file", filePath),
 			log.Err(err))
+		return ""
 	}
+	return filePath
 }
 
 // compileRegexps compiles the given regex strings to regular expressions
@@ -3,9 +3,11 @@ package scraper
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"testing"
 
+	"github.com/cornelk/goscrape/storage"
 	"github.com/cornelk/gotokit/log"
 	"github.com/cornelk/gotokit/set"
 	"github.com/stretchr/testify/assert"
@@ -15,30 +17,31 @@ import (
 func newTestScraper(t *testing.T, startURL string, urls map[string][]byte) *Scraper {
 	t.Helper()
 
-	logger := log.NewTestLogger(t)
 	cfg := Config{
 		URL: startURL,
 	}
+	return newTestScraperWithConfig(t, cfg, urls)
+}
+
+func newTestScraperWithConfig(t *testing.T, cfg Config, urls map[string][]byte) *Scraper {
+	t.Helper()
+
+	if cfg.Storage == nil {
+		cfg.Storage = storage.NewMemory()
+	}
+
+	logger := log.NewTestLogger(t)
 	scraper, err := New(logger, cfg)
 	require.NoError(t, err)
 	require.NotNil(t, scraper)
 
-	scraper.dirCreator = func(_ string) error {
-		return nil
-	}
-	scraper.fileWriter = func(_ string, _ []byte) error {
-		return nil
-	}
-	scraper.fileExistenceCheck = func(_ string) bool {
-		return false
-	}
-	scraper.httpDownloader = func(_ context.Context, url *url.URL) ([]byte, *url.URL, error) {
+	scraper.httpDownloader = func(_ context.Context, url *url.URL, _ *streamTarget) (*httpResponse, error) {
 		ur := url.String()
 		b, ok := urls[ur]
 		if ok {
-			return b, url, nil
+			return &httpResponse{data: b, url: url}, nil
 		}
-		return nil, nil, fmt.Errorf("url '%s' not found in test data", ur)
+		return nil, fmt.Errorf("url '%s' not found in test data: %w", ur, &statusCodeError{code: http.StatusNotFound})
 	}
 
 	return scraper
@@ -95,6 +98,197 @@ func TestScraperLinks(t *testing.T) {
 	assert.Equal(t, expectedProcessed, scraper.processed)
 }
 
+func TestScraperMedia(t *testing.T) {
+	indexPage := []byte(`
+<html>
+<body>
+<video poster="/media/poster.jpg"><source src="/media/movie.mp4"><track src="/media/en.vtt"></video>
+<picture><source srcset="/img/small.webp 480w, /img/large.webp 800w"></picture>
+<iframe src="/frame"></iframe>
+<object data="/doc.pdf"></object>
+</body>
+</html>
+`)
+	framePage := []byte(`<html><body><audio src="/media/sound.mp3"></audio></body></html>`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                 indexPage,
+		fullURL + "/frame":            framePage,
+		fullURL + "/media/poster.jpg": empty,
+		fullURL + "/media/movie.mp4":  empty,
+		fullURL + "/media/en.vtt":     empty,
+		fullURL + "/media/sound.mp3":  empty,
+		fullURL + "/img/small.webp":   empty,
+		fullURL + "/img/large.webp":   empty,
+		fullURL + "/doc.pdf":          empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:     fullURL + "/",
+		Storage: files,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/frame",
+		"/media/poster.jpg",
+		"/media/movie.mp4",
+		"/media/en.vtt",
+		"/media/sound.mp3",
+		"/img/small.webp",
+		"/img/large.webp",
+		"/doc.pdf",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<iframe src="frame.html"></iframe>`)
+	assert.Contains(t, string(index), `<source srcset="img/small.webp 480w, img/large.webp 800w"/>`)
+	assert.Contains(t, string(index), `<video poster="media/poster.jpg">`)
+	assert.True(t, files.Exists("example.org/frame.html"))
+}
+
+func TestScraperInlineStyleAndSVG(t *testing.T) {
+	indexPage := []byte(`
+<html>
+<body>
+<div style="background-image: url(/img/hero.jpg)"></div>
+<svg><image href="/img/chart.png"></image><use href="/icons.svg#logo"></use><use href="#local"></use></svg>
+</body>
+</html>
+`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/sub/":          indexPage,
+		fullURL + "/img/hero.jpg":  empty,
+		fullURL + "/img/chart.png": empty,
+		fullURL + "/icons.svg":     empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:     fullURL + "/sub/",
+		Storage: files,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/sub",
+		"/img/hero.jpg",
+		"/img/chart.png",
+		"/icons.svg",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	index, err := files.ReadFile("example.org/sub/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<div style="background-image: url(&#39;../img/hero.jpg&#39;)"></div>`)
+	assert.Contains(t, string(index), `<image href="../img/chart.png"></image>`)
+	assert.Contains(t, string(index), `<use href="../icons.svg#logo"></use>`)
+	assert.Contains(t, string(index), `<use href="#local"></use>`)
+}
+
+func TestScraperBase(t *testing.T) {
+	indexPage := []byte(`<html><head><base href="/app/"></head><body>
+<a href="docs/intro">Intro</a>
+<a href="https://other.org/">Other</a>
+<img src="img/logo.png" srcset="img/logo-2x.png 2x">
+</body></html>`)
+	introPage := []byte(`<html><body><img src="../img/logo.png"></body></html>`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                    indexPage,
+		fullURL + "/app/docs/intro":      introPage,
+		fullURL + "/app/img/logo.png":    empty,
+		fullURL + "/app/img/logo-2x.png": empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:     fullURL + "/",
+		Storage: files,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/app/docs/intro",
+		"/app/img/logo.png",
+		"/app/img/logo-2x.png",
+		"https://other.org",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Equal(t, `<html><head></head><body>
+<a href="app/docs/intro.html">Intro</a>
+<a href="https://other.org/">Other</a>
+<img src="app/img/logo.png" srcset="app/img/logo-2x.png 2x"/>
+</body></html>`, string(index))
+}
+
+func TestScraperLinkRelations(t *testing.T) {
+	indexPage := []byte(`<html><head>
+<meta http-equiv="refresh" content="0; url=/moved">
+<link rel="canonical" href="https://example.org/">
+<link rel="next" href="/list/2">
+<link rel="icon" href="/favicon.ico">
+<link rel="stylesheet" href="/style.css">
+<link rel="preconnect" href="https://fonts.example.com">
+</head><body></body></html>`)
+	style := []byte(`body { background: url(bg.png); }`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":            indexPage,
+		fullURL + "/moved":       empty,
+		fullURL + "/list/2":      empty,
+		fullURL + "/favicon.ico": empty,
+		fullURL + "/style.css":   style,
+		fullURL + "/bg.png":      empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:     fullURL + "/",
+		Storage: files,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NoError(t, scraper.Start(context.Background()))
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/moved",
+		"/list/2",
+		"/favicon.ico",
+		"/style.css",
+		"/bg.png",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	index, err := files.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<meta http-equiv="refresh" content="0; url=moved.html"/>`)
+	assert.Contains(t, string(index), `<link rel="canonical" href="index.html"/>`)
+	assert.Contains(t, string(index), `<link rel="next" href="list/2.html"/>`)
+	assert.Contains(t, string(index), `<link rel="icon" href="favicon.ico"/>`)
+	assert.Contains(t, string(index), `<link rel="preconnect" href="https://fonts.example.com"/>`)
+}
+
 func TestScraperAttributes(t *testing.T) {
 	indexPage := []byte(`
 <html>
@@ -166,14 +360,13 @@ h3 {
 		fullURL + "/" + file3Reference: empty,
 	}
 
-	scraper := newTestScraper(t, fullURL+"/", urls)
-	require.NotNil(t, scraper)
-
-	files := map[string][]byte{}
-	scraper.fileWriter = func(filePath string, data []byte) error {
-		files[filePath] = data
-		return nil
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:     fullURL + "/",
+		Storage: files,
 	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+	require.NotNil(t, scraper)
 
 	ctx := context.Background()
 	err := scraper.Start(ctx)
@@ -188,8 +381,82 @@ h3 {
 	require.Equal(t, expectedProcessed, scraper.processed)
 
 	ref := domain + "/index.html"
-	content := string(files[ref])
+	data, err := files.ReadFile(ref)
+	require.NoError(t, err)
+	content := string(data)
 	assert.Contains(t, content, "url('"+file1Reference+"')")
 	assert.Contains(t, content, "url('"+file2Reference+"')")
 	assert.Contains(t, content, "url("+file3Reference+")")
 }
+
+func TestScraperConcurrency(t *testing.T) {
+	indexPage := []byte(`
+<html>
+<head>
+<link href="/style.css" rel="stylesheet" type="text/css">
+</head>
+<body>
+<a href="/page1">1</a>
+<a href="/page2">2</a>
+<a href="/page3">3</a>
+<img src="/logo.png"/>
+</body>
+</html>
+`)
+	subPage := []byte(`
+<html>
+<body>
+<a href="/">index</a>
+<a href="/page1">1</a>
+<a href="/page4">4</a>
+<img src="/logo.png"/>
+<img src="/photo.png"/>
+</body>
+</html>
+`)
+	css := []byte(`body { background: url('/bg.png'); }`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":          indexPage,
+		fullURL + "/page1":     subPage,
+		fullURL + "/page2":     subPage,
+		fullURL + "/page3":     subPage,
+		fullURL + "/page4":     subPage,
+		fullURL + "/style.css": css,
+		fullURL + "/bg.png":    empty,
+		fullURL + "/logo.png":  empty,
+		fullURL + "/photo.png": empty,
+	}
+
+	files := storage.NewMemory()
+	cfg := Config{
+		URL:         fullURL + "/",
+		Concurrency: 4,
+		Storage:     files,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+
+	ctx := context.Background()
+	err := scraper.Start(ctx)
+	require.NoError(t, err)
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/page1",
+		"/page2",
+		"/page3",
+		"/page4",
+		"/style.css",
+		"/bg.png",
+		"/logo.png",
+		"/photo.png",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+	paths := files.Paths()
+	assert.Len(t, paths, 9) // the crawl state is removed after the crawl finished
+	page4, err := files.ReadFile("example.org/page4.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(page4), `<a href="page1.html">1</a>`)
+}
@@ -2,10 +2,15 @@ package scraper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"mime"
 	"net/http"
+	"path"
 
+	"github.com/cornelk/goscrape/storage"
 	"github.com/cornelk/gotokit/log"
 )
 
@@ -15,11 +20,19 @@ var mimeTypes = map[string]string{
 	".asp": "text/html; charset=utf-8",
 }
 
-// ServeDirectory serves a directory on a given port as a web server.
+// ServeDirectory serves a directory or a ZIP or tar.gz archive on a given
+// port as a web server.
 func ServeDirectory(ctx context.Context, path string, port int16, logger *log.Logger) error {
-	fs := http.FileServer(http.Dir(path))
+	var fsys http.FileSystem = http.Dir(path)
+	if storage.IsArchive(path) {
+		archive, err := storage.OpenArchive(path)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		fsys = http.FS(archive.FS())
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/", fs) // server root by file system
+	mux.Handle("/", newFileHandler(fsys)) // server root by file system
 
 	// update mime types
 	for ext, mt := range mimeTypes {
@@ -55,3 +68,62 @@ func ServeDirectory(ctx context.Context, path string, port int16, logger *log.Lo
 		return fmt.Errorf("starting webserver: %w", err)
 	}
 }
+
+// fileHandler serves the files of a file system. Requests for missing files
+// are answered with the nearest error page of the website in the directory
+// of the file or one of its parent directories.
+type fileHandler struct {
+	fsys  http.FileSystem
+	files http.Handler
+}
+
+func newFileHandler(fsys http.FileSystem) *fileHandler {
+	return &fileHandler{
+		fsys:  fsys,
+		files: http.FileServer(fsys),
+	}
+}
+
+func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	f, err := h.fsys.Open(name)
+	if err == nil {
+		_ = f.Close()
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+	page, ok := h.errorPage(path.Dir(name))
+	if !ok {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write(page)
+}
+
+// errorPage returns the content of the error page in the directory or the
+// nearest parent directory that contains one.
+func (h *fileHandler) errorPage(dir string) ([]byte, bool) {
+	for {
+		f, err := h.fsys.Open(path.Join(dir, ErrorPageName))
+		if err == nil {
+			data, err := io.ReadAll(f)
+			_ = f.Close()
+			if err == nil {
+				return data, true
+			}
+		}
+
+		if dir == "/" {
+			return nil, false
+		}
+		dir = path.Dir(dir)
+	}
+}
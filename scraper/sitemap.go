@@ -0,0 +1,197 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
+)
+
+const (
+	// maxSitemapNesting limits the recursion of sitemap index files.
+	maxSitemapNesting = 5
+	// maxSitemapSize is the maximum size of an uncompressed sitemap as
+	// defined by the sitemap protocol.
+	maxSitemapSize = 50 << 20
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+
+	errSitemapTooLarge = errors.New("decompressed sitemap exceeds the maximum size")
+)
+
+// sitemapLocation is a location entry of a sitemap or sitemap index file.
+type sitemapLocation struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapDocument represents a sitemap urlset as well as a sitemap index file.
+type sitemapDocument struct {
+	URLs     []sitemapLocation `xml:"url"`
+	Sitemaps []sitemapLocation `xml:"sitemap"`
+}
+
+// parseSitemap parses a sitemap or sitemap index file that can optionally be
+// gzip compressed. It returns the listed page URLs and the URLs of nested
+// sitemap files. A compressed sitemap that decompresses to more than maxSize
+// bytes is rejected.
+func parseSitemap(data []byte, maxSize int64) ([]string, []string, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating gzip reader: %w", err)
+		}
+		// reading one more byte than allowed detects a sitemap that is too large
+		data, err = io.ReadAll(io.LimitReader(reader, maxSize+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("decompressing sitemap: %w", err)
+		}
+		if int64(len(data)) > maxSize {
+			return nil, nil, fmt.Errorf("%w of %d bytes", errSitemapTooLarge, maxSize)
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parsing sitemap XML: %w", err)
+	}
+
+	pages := make([]string, 0, len(doc.URLs))
+	for _, loc := range doc.URLs {
+		if s := strings.TrimSpace(loc.Loc); s != "" {
+			pages = append(pages, s)
+		}
+	}
+	sitemaps := make([]string, 0, len(doc.Sitemaps))
+	for _, loc := range doc.Sitemaps {
+		if s := strings.TrimSpace(loc.Loc); s != "" {
+			sitemaps = append(sitemaps, s)
+		}
+	}
+	return pages, sitemaps, nil
+}
+
+// sitemapURLs returns the URLs of all configured sitemaps and the sitemaps
+// that are listed in the robots.txt file of the start page host.
+func (s *Scraper) sitemapURLs(ctx context.Context) []*url.URL {
+	references := slices.Clone(s.config.Sitemaps)
+	if s.config.DiscoverSitemaps {
+		references = append(references, s.robotsRulesForURL(ctx, s.URL).sitemaps...)
+	}
+
+	var urls []*url.URL
+	for _, reference := range references {
+		u, err := url.Parse(reference)
+		if err != nil {
+			s.logger.Error("Parsing sitemap URL failed",
+				log.String("url", reference),
+				log.Err(err))
+			continue
+		}
+		urls = append(urls, s.URL.ResolveReference(u))
+	}
+	return urls
+}
+
+// seedFromSitemaps downloads all sitemaps and adds the listed pages to the
+// queue of pages to download.
+func (s *Scraper) seedFromSitemaps(ctx context.Context) error {
+	sitemaps := s.sitemapURLs(ctx)
+	if len(sitemaps) == 0 {
+		return nil
+	}
+
+	visited := set.New[string]()
+	var pages []*url.URL
+	for _, sitemap := range sitemaps {
+		found, err := s.downloadSitemap(ctx, sitemap, visited, 0)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, found...)
+	}
+
+	s.logger.Info("Sitemap pages found", log.Int("count", len(pages)))
+	s.enqueuePages(ctx, appendPageLinks(nil, sourceSitemap, pages), 0, 0)
+	return nil
+}
+
+// downloadSitemap downloads a sitemap and returns all listed page URLs,
+// sitemap index files are processed recursively.
+func (s *Scraper) downloadSitemap(ctx context.Context, u *url.URL, visited set.Set[string],
+	nesting int) ([]*url.URL, error) {
+
+	if visited.Contains(u.String()) {
+		return nil, nil
+	}
+	visited.Add(u.String())
+
+	if nesting > maxSitemapNesting {
+		s.logger.Warn("Sitemap nesting too deep", log.String("url", u.String()))
+		return nil, nil
+	}
+
+	s.logger.Info("Downloading sitemap", log.String("url", u.String()))
+	resp, err := s.download(ctx, u, nil)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		s.logger.Error("Downloading sitemap failed",
+			log.String("url", u.String()),
+			log.Err(err))
+		return nil, nil
+	}
+
+	maxSize := int64(maxSitemapSize)
+	if s.config.MaxResponseSize > 0 {
+		maxSize = min(maxSize, s.config.MaxResponseSize)
+	}
+	pageReferences, sitemapReferences, err := parseSitemap(resp.data, maxSize)
+	if err != nil {
+		s.logger.Error("Parsing sitemap failed",
+			log.String("url", u.String()),
+			log.Err(err))
+		return nil, nil
+	}
+
+	var pages []*url.URL
+	for _, reference := range pageReferences {
+		page, err := u.Parse(reference)
+		if err != nil {
+			s.logger.Debug("Parsing sitemap page URL failed",
+				log.String("url", reference),
+				log.Err(err))
+			continue
+		}
+		pages = append(pages, page)
+	}
+
+	for _, reference := range sitemapReferences {
+		sitemap, err := u.Parse(reference)
+		if err != nil {
+			s.logger.Debug("Parsing nested sitemap URL failed",
+				log.String("url", reference),
+				log.Err(err))
+			continue
+		}
+
+		found, err := s.downloadSitemap(ctx, sitemap, visited, nesting+1)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, found...)
+	}
+
+	return pages, nil
+}
@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSitemap(t *testing.T) {
+	index := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.org/sitemap-pages.xml.gz</loc></sitemap>
+</sitemapindex>`)
+
+	pages, sitemaps, err := parseSitemap(index, maxSitemapSize)
+	require.NoError(t, err)
+	assert.Empty(t, pages)
+	assert.Equal(t, []string{"https://example.org/sitemap-pages.xml.gz"}, sitemaps)
+
+	urlSet := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.org/page1 </loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc>https://example.org/page2</loc></url>
+</urlset>`)
+
+	pages, sitemaps, err = parseSitemap(gzipData(t, urlSet), maxSitemapSize)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.org/page1", "https://example.org/page2"}, pages)
+	assert.Empty(t, sitemaps)
+
+	_, _, err = parseSitemap(gzipData(t, urlSet), int64(len(urlSet)))
+	require.NoError(t, err)
+	_, _, err = parseSitemap(gzipData(t, urlSet), int64(len(urlSet)-1))
+	assert.ErrorIs(t, err, errSitemapTooLarge)
+}
+
+func TestScraperSitemap(t *testing.T) {
+	indexPage := []byte(`<html><body></body></html>`)
+	robots := []byte(`Sitemap: https://example.org/sitemap.xml`)
+	index := []byte(`<sitemapindex>
+  <sitemap><loc>/sitemap-pages.xml.gz</loc></sitemap>
+  <sitemap><loc>/sitemap.xml</loc></sitemap>
+</sitemapindex>`)
+	pages := []byte(`<urlset>
+  <url><loc>https://example.org/hidden</loc></url>
+  <url><loc>https://example.org/excluded</loc></url>
+  <url><loc>https://external.org/page</loc></url>
+</urlset>`)
+	empty := []byte(``)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":                     indexPage,
+		fullURL + "/robots.txt":           robots,
+		fullURL + "/sitemap.xml":          index,
+		fullURL + "/sitemap-pages.xml.gz": gzipData(t, pages),
+		fullURL + "/hidden":               empty,
+	}
+
+	cfg := Config{
+		URL:              fullURL + "/",
+		Excludes:         []string{"/excluded"},
+		DiscoverSitemaps: true,
+	}
+	scraper := newTestScraperWithConfig(t, cfg, urls)
+
+	ctx := context.Background()
+	err := scraper.Start(ctx)
+	require.NoError(t, err)
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/hidden",
+		"/excluded",
+		"https://external.org/page",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+	assert.Equal(t, uint(0), scraper.webPageQueueDepth[fullURL+"/hidden"])
+	assert.NotContains(t, scraper.webPageQueueDepth, fullURL+"/excluded")
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,231 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cornelk/gotokit/log"
+)
+
+// StateDirName is the name of the directory in the output directory that the
+// crawl states of unfinished crawls are persisted to, one file per host. It is
+// outside of the host directories to not be part of the mirrored website.
+const StateDirName = ".goscrape-state"
+
+// stateSaveInterval is the minimum interval between two saves of the crawl
+// state while pages are being processed.
+const stateSaveInterval = 5 * time.Second
+
+// urlResult is the result of processing a page or asset URL.
+type urlResult struct {
+	Error string `json:"error,omitempty"`
+	// Links contains the page links of a processed page that have not been
+	// added to the queue yet.
+	Links []string `json:"links,omitempty"`
+	// Sources contains the tags of the elements of the links.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// crawlState is the persisted state of a crawl that allows an interrupted
+// crawl to be resumed.
+type crawlState struct {
+	URL       string               `json:"url"`
+	Queue     []string             `json:"queue"`
+	Depths    map[string]uint      `json:"depths"`
+	HostSpans map[string]uint      `json:"host_spans,omitempty"`
+	Processed map[string]urlResult `json:"processed"`
+}
+
+// stateStore tracks when the crawl state was saved last.
+type stateStore struct {
+	mu        sync.Mutex
+	filePath  string
+	lastSaved time.Time
+}
+
+// recordResult stores the result of processing a page or asset URL.
+func (s *Scraper) recordResult(u *url.URL, err error, links []pageLink) {
+	var result urlResult
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for _, link := range links {
+		result.Links = append(result.Links, link.url.String())
+		result.Sources = append(result.Sources, link.source)
+	}
+
+	s.mu.Lock()
+	s.results[u.String()] = result
+	s.mu.Unlock()
+}
+
+// processedPageLinks returns the page links of an already processed page and
+// whether the page has been processed. This is used for resumed crawls to not
+// process the pages again that were processed before the interruption.
+func (s *Scraper) processedPageLinks(u *url.URL) ([]pageLink, bool) {
+	s.mu.Lock()
+	result, ok := s.results[u.String()]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	links := make([]pageLink, 0, len(result.Links))
+	for i, link := range result.Links {
+		ur, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		var source string
+		if i < len(result.Sources) {
+			source = result.Sources[i]
+		}
+		links = append(links, pageLink{url: ur, source: source})
+	}
+	return links, true
+}
+
+// clearResultLinks removes the stored page links of the given pages after
+// they have been added to the queue.
+func (s *Scraper) clearResultLinks(pages []*url.URL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, page := range pages {
+		result, ok := s.results[page.String()]
+		if ok && result.Links != nil {
+			result.Links = nil
+			result.Sources = nil
+			s.results[page.String()] = result
+		}
+	}
+}
+
+// saveState persists the crawl state to the state file. Unless force is set,
+// the state is only saved if the last save is older than the save interval.
+func (s *Scraper) saveState(force bool) error {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+
+	if !force && time.Since(s.state.lastSaved) < stateSaveInterval {
+		return nil
+	}
+
+	state := crawlState{
+		URL:    s.URL.String(),
+		Queue:  make([]string, 0, len(s.webPageQueue)),
+		Depths: make(map[string]uint, len(s.webPageQueue)),
+	}
+
+	s.mu.Lock()
+	for _, page := range s.webPageQueue {
+		state.Queue = append(state.Queue, page.String())
+		state.Depths[page.String()] = s.webPageQueueDepth[page.String()]
+		if span := s.webPageHostSpan[page.String()]; span > 0 {
+			if state.HostSpans == nil {
+				state.HostSpans = map[string]uint{}
+			}
+			state.HostSpans[page.String()] = span
+		}
+	}
+
+	state.Processed = make(map[string]urlResult, len(s.results))
+	for u, result := range s.results {
+		state.Processed[u] = result
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshaling crawl state: %w", err)
+	}
+
+	if err := s.storage.WriteFile(s.state.filePath, data); err != nil {
+		return fmt.Errorf("writing crawl state: %w", err)
+	}
+	s.state.lastSaved = time.Now()
+	return nil
+}
+
+// saveStateLogged saves the crawl state and logs errors, it is used in code
+// paths where saving the state should not abort the crawl.
+func (s *Scraper) saveStateLogged(force bool) {
+	if err := s.saveState(force); err != nil {
+		s.logger.Error("Saving crawl state failed",
+			log.String("file", s.state.filePath),
+			log.Err(err))
+	}
+}
+
+// removeState removes the state file of a finished crawl, a following crawl
+// with resuming enabled starts from the start page again.
+func (s *Scraper) removeState() {
+	if err := s.storage.Remove(s.state.filePath); err != nil {
+		s.logger.Error("Removing crawl state failed",
+			log.String("file", s.state.filePath),
+			log.Err(err))
+	}
+}
+
+// loadState loads a persisted crawl state and returns whether a state
+// to resume from was found.
+func (s *Scraper) loadState() (bool, error) {
+	data, err := s.storage.ReadFile(s.state.filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reading crawl state: %w", err)
+	}
+
+	var state crawlState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return false, fmt.Errorf("unmarshaling crawl state: %w", err)
+	}
+
+	u, err := url.Parse(state.URL)
+	if err != nil {
+		return false, fmt.Errorf("parsing crawl state URL: %w", err)
+	}
+	s.URL = u
+
+	// only URLs that have a result are marked as processed, URLs that were
+	// filtered or interrupted while downloading get checked again
+	for processed, result := range state.Processed {
+		ur, err := url.Parse(processed)
+		if err != nil {
+			continue
+		}
+		s.results[processed] = result
+		s.processed.Add(s.processedKey(ur))
+	}
+
+	for _, page := range state.Queue {
+		ur, err := url.Parse(page)
+		if err != nil {
+			continue
+		}
+		s.webPageQueue = append(s.webPageQueue, ur)
+		s.webPageQueueDepth[page] = state.Depths[page]
+		s.webPageHostSpan[page] = state.HostSpans[page]
+		s.processed.Add(s.processedKey(ur))
+	}
+
+	s.logger.Info("Resuming crawl",
+		log.String("url", s.URL.String()),
+		log.Int("queued", len(s.webPageQueue)),
+		log.Int("processed", len(s.results)))
+	return true, nil
+}
+
+// stateFilePath returns the path of the state file for the given start URL.
+func stateFilePath(outputDirectory string, u *url.URL) string {
+	return filepath.Join(outputDirectory, StateDirName, strings.ReplaceAll(u.Host, ":", "_")+".json")
+}
@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/cornelk/gotokit/log"
+	"github.com/cornelk/gotokit/set"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperResume(t *testing.T) {
+	indexPage := []byte(`
+<html>
+<body>
+<a href="/page1">1</a>
+<a href="/page2">2</a>
+<a href="/page3">3</a>
+</body>
+</html>
+`)
+	page1 := []byte(`<html><body><a href="/page4">4</a></body></html>`)
+	empty := []byte(`<html><body></body></html>`)
+
+	fullURL := "https://example.org"
+	urls := map[string][]byte{
+		fullURL + "/":      indexPage,
+		fullURL + "/page1": page1,
+		fullURL + "/page2": empty,
+		fullURL + "/page3": empty,
+		fullURL + "/page4": empty,
+	}
+
+	cfg := Config{
+		URL:             fullURL + "/",
+		OutputDirectory: t.TempDir(),
+		IgnoreRobotsTxt: true,
+	}
+
+	newScraper := func(ctx context.Context, cancel context.CancelFunc, downloaded set.Set[string]) *Scraper {
+		logger := log.NewTestLogger(t)
+		if cancel != nil {
+			logger = log.NewNop() // the interruption gets logged as error
+		}
+		s, err := New(logger, cfg)
+		require.NoError(t, err)
+
+		s.httpDownloader = func(_ context.Context, u *url.URL, _ *streamTarget) (*httpResponse, error) {
+			if u.Path == "/page2" && cancel != nil {
+				cancel()
+				return nil, ctx.Err()
+			}
+			downloaded.Add(u.Path)
+			b, ok := urls[u.String()]
+			if !ok {
+				return nil, fmt.Errorf("url '%s' not found in test data", u)
+			}
+			return &httpResponse{data: b, url: u}, nil
+		}
+		return s
+	}
+
+	// first run gets interrupted while downloading page2
+	ctx, cancel := context.WithCancel(context.Background())
+	downloaded := set.New[string]()
+	scraper := newScraper(ctx, cancel, downloaded)
+	err := scraper.Start(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, set.NewFromSlice([]string{"/", "/page1"}), downloaded)
+
+	// resumed run only downloads the missing pages
+	cfg.Resume = true
+	downloaded = set.New[string]()
+	scraper = newScraper(context.Background(), nil, downloaded)
+	err = scraper.Start(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, set.NewFromSlice([]string{"/page2", "/page3", "/page4"}), downloaded)
+
+	expectedProcessed := set.NewFromSlice([]string{
+		"/",
+		"/page1",
+		"/page2",
+		"/page3",
+		"/page4",
+	})
+	assert.Equal(t, expectedProcessed, scraper.processed)
+
+	// the state of a finished crawl is removed, resuming it starts a new crawl
+	assert.NoFileExists(t, filepath.Join(cfg.OutputDirectory, StateDirName, "example.org.json"))
+	downloaded = set.New[string]()
+	scraper = newScraper(context.Background(), nil, downloaded)
+	err = scraper.Start(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, set.NewFromSlice([]string{"/", "/page1", "/page2", "/page3", "/page4"}), downloaded)
+}
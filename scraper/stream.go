@@ -0,0 +1,244 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+)
+
+// sniffLength is the number of bytes of a streamed body that are kept in
+// memory to detect the content type, it matches http.DetectContentType.
+const sniffLength = 512
+
+// streamTarget decides whether the body of a response is streamed to a file.
+type streamTarget struct {
+	// filePath returns the path of the file to stream the body of a response
+	// with the given final URL and Content-Type to. An empty path reads the
+	// body into memory, which is needed for pages and stylesheets whose
+	// references get relinked.
+	filePath func(final *url.URL, contentType string) string
+	// resumePath is the file path whose partial file of an interrupted
+	// download is resumed, it has to be known before the request is sent
+	resumePath string
+}
+
+// readBody reads the body of a response into memory or streams it to the
+// file that the stream target returns. The body of a range response is
+// appended to the partial file. The SHA-256 digest of the body is calculated
+// while reading. If WARC output is enabled, a streamed body is additionally
+// spooled to a temporary file that the WARC record is written from. Without
+// a browsable website only the spool is written.
+func (s *Scraper) readBody(u *url.URL, resp *http.Response, stream *streamTarget,
+	partial *partialDownload) (*httpResponse, error) {
+
+	result := &httpResponse{
+		url:         resp.Request.URL,
+		contentType: resp.Header.Get("Content-Type"),
+	}
+
+	var offset int64
+	expectedSize := resp.ContentLength // -1 if unknown
+	if partial != nil {
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != partial.size {
+			return nil, fmt.Errorf("%w: '%s' for a partial file of %d bytes",
+				errUnexpectedRange, resp.Header.Get("Content-Range"), partial.size)
+		}
+		offset = partial.size
+		expectedSize = total
+	}
+
+	maxSize := s.config.MaxResponseSize
+	if maxSize > 0 && resp.ContentLength >= 0 && offset+resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w of %d bytes: content length %d", errResponseTooLarge, maxSize, offset+resp.ContentLength)
+	}
+
+	body := io.Reader(resp.Body)
+	if maxSize > 0 {
+		// the content length is unknown or wrong, reading one more byte
+		// than allowed detects a body that is too large
+		body = io.LimitReader(resp.Body, maxSize+1-offset)
+	}
+
+	hash := sha256.New()
+	head := &prefixWriter{limit: sniffLength}
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	resumable := partial != nil
+
+	switch {
+	case partial != nil:
+		file, err := storage.ResumeFile(s.storage, partial.filePath, io.MultiWriter(hash, head))
+		if err != nil {
+			return nil, fmt.Errorf("resuming partial file: %w", err)
+		}
+		result.file = file
+		result.filePath = partial.filePath
+		w = io.MultiWriter(file, head)
+
+	case stream != nil && s.config.WARCOnly:
+		if stream.filePath(result.url, result.contentType) == "" {
+			break
+		}
+		result.file = discardingFile{} // only the spool is written
+		w = head
+
+	case stream != nil:
+		result.filePath = stream.filePath(result.url, result.contentType)
+		if result.filePath == "" {
+			break
+		}
+
+		var info []byte
+		if result.filePath == stream.resumePath {
+			info = resumeInfo(u, resp)
+		}
+		file, err := storage.CreateFile(s.storage, result.filePath, info)
+		if err != nil {
+			return nil, fmt.Errorf("creating file for streaming: %w", err)
+		}
+		result.file = file
+		resumable = info != nil
+		w = io.MultiWriter(file, head)
+	}
+
+	if s.warc != nil && result.file != nil {
+		spool, err := os.CreateTemp("", "goscrape-warc-*")
+		if err != nil {
+			s.discardFile(result)
+			return nil, fmt.Errorf("creating WARC spool file: %w", err)
+		}
+		result.spool = spool
+		w = io.MultiWriter(w, spool)
+	}
+
+	written, err := io.Copy(io.MultiWriter(w, hash), body)
+	size := offset + written
+	if err == nil && expectedSize >= 0 && size != expectedSize {
+		err = fmt.Errorf("%w: %d of %d bytes", errIncompleteBody, size, expectedSize)
+	}
+	if err != nil {
+		s.removeSpool(result.spool)
+		if resumable && result.file != nil && (expectedSize < 0 || size < expectedSize) {
+			s.keepPartialFile(u, result)
+		} else {
+			s.discardFile(result)
+		}
+		return nil, fmt.Errorf("reading HTTP request body: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		s.removeSpool(result.spool)
+		s.discardFile(result)
+		return nil, fmt.Errorf("%w of %d bytes", errResponseTooLarge, maxSize)
+	}
+
+	result.size = size
+	result.digest = hex.EncodeToString(hash.Sum(nil))
+	if result.file == nil {
+		result.data = buf.Bytes()
+	} else {
+		result.head = head.data
+	}
+	return result, nil
+}
+
+// bodySize returns the size of the response body.
+func (r *httpResponse) bodySize() int64 {
+	if r.file != nil {
+		return r.size
+	}
+	return int64(len(r.data))
+}
+
+// bodyStart returns the body or the first bytes of a streamed body, which
+// are used to detect the content type.
+func (r *httpResponse) bodyStart() []byte {
+	if r.file != nil {
+		return r.head
+	}
+	return r.data
+}
+
+// commitFile replaces the file of a streamed response with the streamed data.
+// It returns the path of the written file or an empty string on failure or
+// if no browsable website is written.
+func (s *Scraper) commitFile(u *url.URL, resp *httpResponse) string {
+	if s.config.WARCOnly {
+		return ""
+	}
+
+	if err := resp.file.Commit(); err != nil {
+		s.logger.Error("Writing to file failed",
+			log.String("URL", u.String()),
+			log.String("file", resp.filePath),
+			log.Err(err))
+		return ""
+	}
+
+	s.logger.Debug("Response streamed to file",
+		log.String("url", u.String()),
+		log.String("file", resp.filePath),
+		log.Int64("size", resp.size),
+		log.String("sha256", resp.digest))
+	return resp.filePath
+}
+
+// keepPartialFile closes the file of a streamed response whose download
+// failed and keeps the partial file to resume the download later.
+func (s *Scraper) keepPartialFile(u *url.URL, resp *httpResponse) {
+	if err := resp.file.Close(); err != nil {
+		s.logger.Error("Closing partial file failed",
+			log.String("file", resp.filePath),
+			log.Err(err))
+		return
+	}
+
+	s.logger.Info("Keeping partial download to resume it",
+		log.String("url", u.String()),
+		log.String("file", resp.filePath))
+}
+
+// discardFile removes the streamed data of a response that is not stored,
+// it does nothing for responses that were read into memory.
+func (s *Scraper) discardFile(resp *httpResponse) {
+	if resp == nil || resp.file == nil {
+		return
+	}
+
+	if err := resp.file.Abort(); err != nil {
+		s.logger.Error("Removing streamed file failed",
+			log.String("file", resp.filePath),
+			log.Err(err))
+	}
+}
+
+// discardingFile is the file of a streamed response whose body is only
+// written to the WARC output.
+type discardingFile struct{}
+
+func (discardingFile) Write(p []byte) (int, error) { return len(p), nil }
+func (discardingFile) Commit() error               { return nil }
+func (discardingFile) Abort() error                { return nil }
+func (discardingFile) Close() error                { return nil }
+
+// prefixWriter keeps the first bytes that are written to it up to the limit
+// and discards the rest.
+type prefixWriter struct {
+	limit int
+	data  []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if remaining := w.limit - len(w.data); remaining > 0 {
+		w.data = append(w.data, p[:min(remaining, len(p))]...)
+	}
+	return len(p), nil
+}
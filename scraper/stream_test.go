@@ -0,0 +1,122 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cornelk/goscrape/storage"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	files := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"/":          {"text/html", `<a href="/video.mp4">Video</a><link rel="stylesheet" href="/style.css"><img src="/big.png">`},
+		"/video.mp4": {"video/mp4", strings.Repeat("v", 2000)},
+		"/style.css": {"text/css", `body { background: url(/img.png); }`},
+		"/img.png":   {"image/png", "png"},
+		"/big.png":   {"image/png", strings.Repeat("p", 100)},
+	}
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", file.contentType)
+		_, err := w.Write([]byte(file.body))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestDownloadURLStream(t *testing.T) {
+	svr := newStreamTestServer(t)
+	dir := t.TempDir()
+
+	s, err := New(log.NewTestLogger(t), Config{URL: svr.URL})
+	require.NoError(t, err)
+
+	filePath := filepath.Join(dir, "video.mp4")
+	stream := &streamTarget{
+		filePath: func(_ *url.URL, contentType string) string {
+			assert.Equal(t, "video/mp4", contentType)
+			return filePath
+		},
+	}
+
+	u, err := url.Parse(svr.URL + "/video.mp4")
+	require.NoError(t, err)
+	resp, err := s.downloadURLWithRetries(context.Background(), u, stream)
+	require.NoError(t, err)
+
+	body := strings.Repeat("v", 2000)
+	sum := sha256.Sum256([]byte(body))
+	assert.Nil(t, resp.data)
+	assert.Equal(t, int64(len(body)), resp.bodySize())
+	assert.Equal(t, body[:sniffLength], string(resp.bodyStart()))
+	assert.Equal(t, hex.EncodeToString(sum[:]), resp.digest)
+
+	assert.NoFileExists(t, filePath)
+	assert.FileExists(t, filePath+storage.PartialFileSuffix)
+	assert.Equal(t, filePath, s.commitFile(u, resp))
+	assert.NoFileExists(t, filePath+storage.PartialFileSuffix)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestScraperStream(t *testing.T) {
+	svr := newStreamTestServer(t)
+	dir := t.TempDir()
+
+	cfg := Config{
+		URL:             svr.URL,
+		OutputDirectory: dir,
+		IgnoreRobotsTxt: true,
+		AssetRules:      []string{"exclude size>50"},
+	}
+	s, err := New(log.NewTestLogger(t), cfg)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	hostDir := filepath.Join(dir, s.URL.Host)
+	data, err := os.ReadFile(filepath.Join(hostDir, "video.mp4"))
+	require.NoError(t, err)
+	assert.Len(t, data, 2000)
+
+	data, err = os.ReadFile(filepath.Join(hostDir, "style.css"))
+	require.NoError(t, err)
+	assert.Equal(t, `body { background: url('img.png'); }`, string(data))
+	assert.FileExists(t, filepath.Join(hostDir, "img.png"))
+
+	// the streamed asset is removed as the rule excludes it after the download
+	assert.NoFileExists(t, filepath.Join(hostDir, "big.png"))
+
+	var partialFiles []string
+	err = filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		if strings.HasSuffix(path, storage.PartialFileSuffix) {
+			partialFiles = append(partialFiles, path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Empty(t, partialFiles)
+}
@@ -7,7 +7,43 @@ import (
 	"strings"
 )
 
-func resolveURL(base *url.URL, reference, mainPageHost string, isHyperlink bool, relativeToRoot string) string {
+// mirror describes how the URLs of the scraped hosts map to the files of
+// the mirrored website.
+type mirror struct {
+	mainHost string      // host whose files are stored in the root directory
+	query    queryFilter // filter of the query parameters that are part of file names
+
+	// isPageHost returns whether the pages of another host are mirrored,
+	// hyperlinks to hosts that are not mirrored are not changed. It can be
+	// nil if no pages of other hosts are mirrored.
+	isPageHost func(host string) bool
+	// isAssetHost returns whether the assets of another host are mirrored,
+	// references to hosts that are not mirrored are not changed. It can be
+	// nil if the assets of all hosts are mirrored.
+	isAssetHost func(host string) bool
+}
+
+// mirrorsPagesOf returns whether the pages of the host are mirrored.
+func (m mirror) mirrorsPagesOf(host string) bool {
+	return host == m.mainHost || (m.isPageHost != nil && m.isPageHost(host))
+}
+
+// mirrorsAssetsOf returns whether the assets of the host are mirrored.
+func (m mirror) mirrorsAssetsOf(host string) bool {
+	return host == m.mainHost || m.isAssetHost == nil || m.isAssetHost(host)
+}
+
+// absoluteRoot is the relative root path of pages that are served for URLs
+// of any directory, resolveURL returns root-absolute paths for them.
+const absoluteRoot = "/"
+
+// resolveURL resolves the reference against the base URL and returns the
+// path of the referenced file relative to the file of the base page.
+// Files of other hosts than the main host are stored in a subdirectory named
+// by the host with a _ prefix.
+func resolveURL(base *url.URL, reference string, m mirror, isHyperlink bool, relativeToRoot string) string {
+	mainPageHost, query := m.mainHost, m.query
+
 	ur, err := url.Parse(reference)
 	if err != nil {
 		return ""
@@ -15,22 +51,36 @@ func resolveURL(base *url.URL, reference, mainPageHost string, isHyperlink bool,
 
 	var resolvedURL *url.URL
 	if ur.Host != "" && ur.Host != mainPageHost {
-		if isHyperlink { // do not change links to external websites
+		if isHyperlink && !m.mirrorsPagesOf(ur.Host) { // do not change links to external websites
+			return reference
+		}
+		if !isHyperlink && !m.mirrorsAssetsOf(ur.Host) {
 			return reference
 		}
 
 		resolvedURL = base.ResolveReference(ur)
-		resolvedURL.Path = filepath.Join("_"+ur.Host, resolvedURL.Path)
+		if isHyperlink {
+			resolvedURL.Path = getPageFilePath(resolvedURL)
+		}
+		resolvedURL.Path = query.fileNameWithQuery(resolvedURL.Path, resolvedURL.RawQuery)
 	} else {
+		if ur.Path == "" && ur.RawQuery != "" {
+			ur.Path = base.Path // query only references point to the base page
+		}
 		if isHyperlink {
 			ur.Path = getPageFilePath(ur)
-			resolvedURL = base.ResolveReference(ur)
-		} else {
-			resolvedURL = base.ResolveReference(ur)
 		}
+		ur.Path = query.fileNameWithQuery(ur.Path, ur.RawQuery)
+		resolvedURL = base.ResolveReference(ur)
 	}
-
-	if resolvedURL.Host == mainPageHost {
+	resolvedURL.RawQuery = "" // the query is part of the file name
+
+	// files of the main host are linked relative to each other, all other
+	// files are linked relative to the root directory
+	switch {
+	case resolvedURL.Host != mainPageHost:
+		resolvedURL.Path = filepath.Join("_"+resolvedURL.Host, resolvedURL.Path)
+	case base.Host == mainPageHost && relativeToRoot != absoluteRoot:
 		resolvedURL.Path = urlRelativeToOther(resolvedURL, base)
 		relativeToRoot = ""
 	}
@@ -60,10 +110,35 @@ func resolveURL(base *url.URL, reference, mainPageHost string, isHyperlink bool,
 		}
 	}
 
+	if relativeToRoot == absoluteRoot {
+		return resolved
+	}
 	resolved = strings.TrimPrefix(resolved, "/")
 	return resolved
 }
 
+// mirror returns how URLs map to the mirrored files for the references of
+// the given page. Hyperlinks to pages of other hosts are relinked if the
+// pages get downloaded based on the host span of the page.
+func (s *Scraper) mirror(page *url.URL) mirror {
+	mainHost := s.URL.Host
+	s.mu.Lock()
+	hostSpan := s.webPageHostSpan[page.String()]
+	s.mu.Unlock()
+
+	return mirror{
+		mainHost: mainHost,
+		query:    s.query,
+		isPageHost: func(host string) bool {
+			_, ok := s.domains.hostSpan(mainHost, host, hostSpan)
+			return ok
+		},
+		isAssetHost: func(host string) bool {
+			return s.domains.isAssetHostAllowed(mainHost, host)
+		},
+	}
+}
+
 func urlRelativeToRoot(url *url.URL) string {
 	var rel strings.Builder
 	splits := strings.Split(url.Path, "/")
@@ -34,10 +34,17 @@ func TestResolveURL(t *testing.T) {
 		{URL, "brasil/index.html", true, "", "brasil/index.html"},
 		{URL, "brasil/rio/index.html", true, "", "brasil/rio/index.html"},
 		{URL, "../argentina/cat.jpg", false, "", "../argentina/cat.jpg"},
+		{URL, "brasil/?page=2", true, "", "brasil/index_page=2.html"},
+		{URL, "?page=2", true, "", "index_page=2.html"},
+		{URL, "../argentina/cat.jpg?size=small", false, "", "../argentina/cat_size=small.jpg"},
+		{URL, "https://cdn.org/cat.jpg?size=small", false, "../", "../_cdn.org/cat_size=small.jpg"},
+		{URL, "brasil/", true, absoluteRoot, "/earth/brasil/index.html"},
+		{URL, "../argentina/cat.jpg", false, absoluteRoot, "/argentina/cat.jpg"},
+		{URL, "https://cdn.org/cat.jpg", false, absoluteRoot, "/_cdn.org/cat.jpg"},
 	}
 
 	for _, fix := range fixtures {
-		resolved := resolveURL(&fix.BaseURL, fix.Reference, URL.Host, fix.IsHyperlink, fix.RelativeToRoot)
+		resolved := resolveURL(&fix.BaseURL, fix.Reference, mirror{mainHost: URL.Host}, fix.IsHyperlink, fix.RelativeToRoot)
 		assert.Equal(t, fix.Resolved, resolved)
 	}
 }
@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cornelk/goscrape/warc"
+	"github.com/cornelk/gotokit/log"
+)
+
+// newWARCWriter returns a WARC writer that names the files after the host
+// of the start URL.
+func newWARCWriter(cfg Config, u *url.URL) *warc.Writer {
+	return warc.NewWriter(warc.Config{
+		Directory:   cfg.WARCDirectory,
+		Prefix:      "goscrape-" + strings.ReplaceAll(u.Host, ":", "_"),
+		MaxFileSize: cfg.WARCMaxFileSize,
+		Software:    "goscrape",
+	})
+}
+
+// maxRedirectBodySize is the maximum size of the body of a redirect response
+// that is archived, the client discards the bodies of followed redirects.
+const maxRedirectBodySize = 1 << 20
+
+// redirectArchiver is a transport that writes the HTTP exchanges of redirect
+// responses to the WARC output. The client follows redirects without
+// returning their responses, the final responses are archived after their
+// body was read.
+type redirectArchiver struct {
+	transport http.RoundTripper
+	warc      *warc.Writer
+	logger    *log.Logger
+}
+
+func (a *redirectArchiver) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestTime := time.Now()
+	resp, err := a.transport.RoundTrip(req)
+	if err != nil || !isFollowedRedirect(resp) {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRedirectBodySize))
+	_ = resp.Body.Close()
+	if err != nil {
+		a.logger.Debug("Reading redirect response body failed",
+			log.String("url", req.URL.String()),
+			log.Err(err))
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := a.warc.WriteExchange(resp, body, requestTime); err != nil {
+		a.logger.Error("Writing WARC record failed",
+			log.String("url", req.URL.String()),
+			log.Err(err))
+	}
+	return resp, nil
+}
+
+// isFollowedRedirect returns whether the client follows the redirect of the
+// response.
+func isFollowedRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	default:
+		return false
+	}
+}
+
+// archiveResponse writes the HTTP exchange of the response to the WARC
+// output if it is enabled.
+func (s *Scraper) archiveResponse(resp *http.Response, body []byte, requestTime time.Time) {
+	if s.warc == nil {
+		return
+	}
+
+	if err := s.warc.WriteExchange(resp, body, requestTime); err != nil {
+		s.logger.Error("Writing WARC record failed",
+			log.String("url", resp.Request.URL.String()),
+			log.Err(err))
+	}
+}
+
+// archiveSpooledResponse writes the HTTP exchange of the response whose body
+// was spooled to a temporary file to the WARC output and removes the file.
+func (s *Scraper) archiveSpooledResponse(resp *http.Response, spool *os.File, requestTime time.Time) {
+	defer s.removeSpool(spool)
+
+	if err := s.warc.WriteStreamedExchange(resp, spool, requestTime); err != nil {
+		s.logger.Error("Writing WARC record failed",
+			log.String("url", resp.Request.URL.String()),
+			log.Err(err))
+	}
+}
+
+// removeSpool closes and removes the temporary file that the body of a
+// response was spooled to, it does nothing if there is none.
+func (s *Scraper) removeSpool(spool *os.File) {
+	if spool == nil {
+		return
+	}
+
+	_ = spool.Close()
+	if err := os.Remove(spool.Name()); err != nil {
+		s.logger.Error("Removing WARC spool file failed",
+			log.String("file", spool.Name()),
+			log.Err(err))
+	}
+}
+
+// archiveUnreadResponse reads the body of a response that is not processed
+// any further and writes the HTTP exchange to the WARC output if it is enabled.
+func (s *Scraper) archiveUnreadResponse(resp *http.Response, requestTime time.Time) {
+	if s.warc == nil {
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.Error("Reading HTTP request body failed",
+			log.String("url", resp.Request.URL.String()),
+			log.Err(err))
+	}
+	s.archiveResponse(resp, body, requestTime)
+}
+
+// closeWARC closes the current WARC file and logs errors.
+func (s *Scraper) closeWARC() {
+	if s.warc == nil {
+		return
+	}
+
+	if err := s.warc.Close(); err != nil {
+		s.logger.Error("Closing WARC file failed", log.Err(err))
+	}
+}
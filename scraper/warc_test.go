@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cornelk/goscrape/warc"
+	"github.com/cornelk/gotokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperWARC(t *testing.T) {
+	pages := map[string]string{
+		"/":        `<html><body><a href="/missing">missing</a><a href="/moved">moved</a><img src="/img.png"/></body></html>`,
+		"/page":    `<html><body>page</body></html>`,
+		"/img.png": "image",
+	}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		}
+		content, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer svr.Close()
+
+	cfg := Config{
+		URL:             svr.URL + "/",
+		OutputDirectory: t.TempDir(),
+		IgnoreRobotsTxt: true,
+		WARCDirectory:   t.TempDir(),
+		WARCOnly:        true,
+	}
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)      // streamed bodies are spooled to temporary files
+	s, err := New(log.NewNop(), cfg) // the missing page gets logged as error
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	files, err := filepath.Glob(filepath.Join(cfg.WARCDirectory, "*"+warc.FileExtension))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content := readWARCFile(t, files[0])
+
+	for _, path := range []string{"/", "/img.png", "/missing", "/moved", "/page"} {
+		assert.Contains(t, content, "WARC-Target-URI: "+svr.URL+path+"\r\n")
+	}
+	assert.Contains(t, content, "HTTP/1.1 404 Not Found\r\n")
+	assert.Contains(t, content, "HTTP/1.1 302 Found\r\n")
+	assert.Contains(t, content, "Location: /page\r\n")
+	assert.NotContains(t, content, "Host: \r\n")
+	assert.Contains(t, content, "WARC-Payload-Digest: "+warc.Digest([]byte("image"))+"\r\n")
+
+	indexFile := filepath.Join(cfg.OutputDirectory, strings.TrimPrefix(svr.URL, "http://"), "index.html")
+	assert.NoFileExists(t, indexFile)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(indexFile), "img.png"))
+	spools, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, spools)
+}
+
+func TestDownloadURLResumeWARC(t *testing.T) {
+	body := strings.Repeat("0123456789", 200)
+	svr, _ := newResumeTestServer(t, body, true)
+	dir := t.TempDir()
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	s, err := New(log.NewTestLogger(t), Config{URL: svr.URL, WARCDirectory: dir})
+	require.NoError(t, err)
+
+	filePath := filepath.Join(dir, "video.mp4")
+	stream := &streamTarget{
+		filePath:   func(*url.URL, string) string { return filePath },
+		resumePath: filePath,
+	}
+	u, err := url.Parse(svr.URL + "/video.mp4")
+	require.NoError(t, err)
+
+	_, err = s.downloadURLWithRetries(context.Background(), u, stream)
+	require.Error(t, err)
+	partial := s.resumablePartial(u, stream)
+	require.NotNil(t, partial)
+	assert.Equal(t, int64(len(body)/2), partial.size)
+
+	resp, err := s.downloadURLWithRetries(context.Background(), u, stream)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), resp.bodySize())
+	assert.Equal(t, filePath, s.commitFile(u, resp))
+	s.closeWARC()
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+warc.FileExtension))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content := readWARCFile(t, files[0])
+	// the resumed download is recorded as the range response that completed it
+	assert.Contains(t, content, "HTTP/1.1 206 Partial Content\r\n")
+	assert.Contains(t, content, "WARC-Payload-Digest: "+warc.Digest([]byte(body[len(body)/2:]))+"\r\n")
+
+	spools, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, spools)
+}
+
+func readWARCFile(t *testing.T, fileName string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader) // reads all gzip members
+	require.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// workerCount returns the number of concurrent workers to use for downloads.
+func (s *Scraper) workerCount() int {
+	if s.config.Concurrency <= 1 {
+		return 1
+	}
+	return int(s.config.Concurrency)
+}
+
+// forEach calls fn for every index in [0, count) using a bounded pool of
+// workers. With a concurrency of 1 all calls are done sequentially in the
+// calling goroutine. Processing of new indexes stops after the first returned
+// error, which is returned once all running workers have finished.
+func (s *Scraper) forEach(ctx context.Context, count int, fn func(i int) error) error {
+	workers := min(s.workerCount(), count)
+	if workers <= 1 {
+		for i := range count {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range count {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// download executes the HTTP downloader and adds the size of the response
+// body to the total size of the crawl.
+func (s *Scraper) download(ctx context.Context, u *url.URL, stream *streamTarget) (*httpResponse, error) {
+	resp, err := s.httpDownloader(ctx, u, stream)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addDownloadedSize(ctx, resp.bodySize()); err != nil {
+		s.discardFile(resp)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// acquireDownloadSlot blocks until a connection slot of the host is
+// available, the crawl delay of the host passed and a download slot is
+// available. The download slots limit the number of in-flight requests to
+// the configured concurrency, they are taken last so that requests waiting
+// for a busy host do not block the downloads of other hosts. The returned
+// function has to be called to release both slots.
+func (s *Scraper) acquireDownloadSlot(ctx context.Context, host string) (func(), error) {
+	releaseHost, err := s.politeness.acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.politeness.wait(ctx, host); err != nil {
+		releaseHost()
+		return nil, err
+	}
+
+	select {
+	case s.downloadSlots <- struct{}{}:
+	case <-ctx.Done():
+		releaseHost()
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-s.downloadSlots
+		releaseHost()
+	}, nil
+}
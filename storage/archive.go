@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var errUnsupportedArchive = errors.New("unsupported archive format, supported are .zip, .tar.gz and .tgz")
+
+// archiveFormat is the format of an archive file.
+type archiveFormat int
+
+const (
+	formatZip archiveFormat = iota + 1
+	formatTarGz
+)
+
+// archiveFormatForPath returns the archive format based on the file extension.
+func archiveFormatForPath(filePath string) (archiveFormat, bool) {
+	lower := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip, true
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz, true
+	default:
+		return 0, false
+	}
+}
+
+// IsArchive returns whether the file path has the extension of a supported
+// archive format.
+func IsArchive(filePath string) bool {
+	_, ok := archiveFormatForPath(filePath)
+	return ok
+}
+
+// Archive is a storage that writes all files to a ZIP or tar.gz archive file
+// when it gets closed. The files are kept in memory until then, which allows
+// files like the crawl state to be overwritten during the scrape.
+type Archive struct {
+	*Memory
+
+	filePath      string
+	format        archiveFormat
+	rootDirectory string // clean path of the directory that entries are relative to
+}
+
+// NewArchive returns a new archive storage that writes to the given file,
+// the format is chosen based on the file extension. The entries of the
+// archive are stored relative to the root directory, which is usually the
+// output directory that is part of all written file paths.
+func NewArchive(filePath, rootDirectory string) (*Archive, error) {
+	format, ok := archiveFormatForPath(filePath)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedArchive, filePath)
+	}
+
+	return &Archive{
+		Memory:        NewMemory(),
+		filePath:      filePath,
+		format:        format,
+		rootDirectory: cleanPath(rootDirectory),
+	}, nil
+}
+
+// entryName returns the name of the archive entry for the stored path. Paths
+// outside of the root directory keep their full path.
+func (a *Archive) entryName(storedPath string) string {
+	if a.rootDirectory == "." {
+		return storedPath
+	}
+	if name, ok := strings.CutPrefix(storedPath, a.rootDirectory+"/"); ok {
+		return name
+	}
+	return storedPath
+}
+
+// Close writes all stored files to the archive file.
+func (a *Archive) Close() error {
+	f, err := os.Create(a.filePath)
+	if err != nil {
+		return fmt.Errorf("creating archive file: %w", err)
+	}
+
+	if a.format == formatZip {
+		err = a.writeZip(f)
+	} else {
+		err = a.writeTarGz(f)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(a.filePath)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing archive file: %w", err)
+	}
+	return nil
+}
+
+func (a *Archive) writeZip(w io.Writer) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	zw := zip.NewWriter(w)
+	for _, name := range a.sortedPaths() {
+		file := a.files[name]
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     a.entryName(name),
+			Method:   zip.Deflate,
+			Modified: file.modTime,
+		})
+		if err != nil {
+			return fmt.Errorf("creating zip entry: %w", err)
+		}
+		if _, err := fw.Write(file.data); err != nil {
+			return fmt.Errorf("writing zip entry: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("closing zip writer: %w", err)
+	}
+	return nil
+}
+
+func (a *Archive) writeTarGz(w io.Writer) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range a.sortedPaths() {
+		file := a.files[name]
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     a.entryName(name),
+			Mode:     0o644,
+			Size:     int64(len(file.data)),
+			ModTime:  file.modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("writing tar header: %w", err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return fmt.Errorf("writing tar entry: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar writer: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("closing gzip writer: %w", err)
+	}
+	return nil
+}
+
+// OpenArchive reads all files of a ZIP or tar.gz archive file into a memory
+// storage, its FS method can be used to serve the archived website.
+func OpenArchive(filePath string) (*Memory, error) {
+	format, ok := archiveFormatForPath(filePath)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedArchive, filePath)
+	}
+
+	if format == formatZip {
+		return readZip(filePath)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return readTarGz(f)
+}
+
+func readZip(filePath string) (*Memory, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening zip file: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	m := NewMemory()
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening zip entry: %w", err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading zip entry: %w", err)
+		}
+		m.store(file.Name, data, file.Modified)
+	}
+	return m, nil
+}
+
+func readTarGz(r io.Reader) (*Memory, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip stream: %w", err)
+	}
+	tr := tar.NewReader(gr)
+
+	m := NewMemory()
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar header: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading tar entry: %w", err)
+		}
+		m.store(header.Name, data, header.ModTime)
+	}
+}
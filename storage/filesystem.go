@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem is a storage that writes the files to the local file system.
+type FileSystem struct{}
+
+// NewFileSystem returns a new file system storage.
+func NewFileSystem() *FileSystem {
+	return &FileSystem{}
+}
+
+// WriteFile writes the data to the file and creates all missing parent
+// directories. A partially written file is removed.
+func (f *FileSystem) WriteFile(filePath string, data []byte) error {
+	if dir := filepath.Dir(filePath); dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("creating directory '%s': %w", dir, err)
+		}
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("creating file '%s': %w", filePath, err)
+	}
+
+	if _, err = file.Write(data); err != nil {
+		// nolint: wrapcheck
+		_ = file.Close() // try to close and remove file but return the first error
+		_ = os.Remove(filePath)
+		return fmt.Errorf("writing to file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+	return nil
+}
+
+// ReadFile returns the content of the file.
+func (f *FileSystem) ReadFile(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return data, nil
+}
+
+// Exists returns whether the file exists.
+func (f *FileSystem) Exists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return !os.IsNotExist(err)
+}
+
+// Remove removes the file if it exists.
+func (f *FileSystem) Remove(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a storage that keeps all files in memory. Its FS method returns
+// an fs.FS to allow serving or inspecting the files without touching the disk.
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile // key is the cleaned path
+}
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemory returns a new empty memory storage.
+func NewMemory() *Memory {
+	return &Memory{
+		files: map[string]memoryFile{},
+	}
+}
+
+// WriteFile stores a copy of the data.
+func (m *Memory) WriteFile(filePath string, data []byte) error {
+	m.store(filePath, bytes.Clone(data), time.Now())
+	return nil
+}
+
+func (m *Memory) store(filePath string, data []byte, modTime time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[cleanPath(filePath)] = memoryFile{
+		data:    data,
+		modTime: modTime,
+	}
+}
+
+// ReadFile returns the content of the file.
+func (m *Memory) ReadFile(filePath string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[cleanPath(filePath)]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: filePath, Err: fs.ErrNotExist}
+	}
+	return bytes.Clone(file.data), nil
+}
+
+// Exists returns whether the file exists.
+func (m *Memory) Exists(filePath string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.files[cleanPath(filePath)]
+	return ok
+}
+
+// Remove removes the file if it exists.
+func (m *Memory) Remove(filePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.files, cleanPath(filePath))
+	return nil
+}
+
+// Paths returns the sorted slash separated paths of all stored files.
+func (m *Memory) Paths() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sortedPaths()
+}
+
+// sortedPaths returns the sorted paths of all stored files. The caller has
+// to hold the read lock.
+func (m *Memory) sortedPaths() []string {
+	paths := make([]string, 0, len(m.files))
+	for name := range m.files {
+		paths = append(paths, name)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// FS returns a read only fs.FS view of the stored files.
+func (m *Memory) FS() fs.FS {
+	return memoryFS{m: m}
+}
+
+// memoryFS implements fs.FS for the memory storage. It is a separate type as
+// the storage accepts file system paths that are not valid for fs.FS.
+type memoryFS struct {
+	m *Memory
+}
+
+// Open opens the named file or directory.
+func (f memoryFS) Open(name string) (fs.File, error) {
+	m := f.m
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if file, ok := m.files[name]; ok {
+		return &openFile{
+			Reader: bytes.NewReader(file.data),
+			info: fileInfo{
+				name:    path.Base(name),
+				size:    int64(len(file.data)),
+				modTime: file.modTime,
+			},
+		}, nil
+	}
+
+	entries := m.dirEntries(name)
+	if entries == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &openDir{
+		info: fileInfo{
+			name: path.Base(name),
+			mode: fs.ModeDir | 0o555,
+		},
+		entries: entries,
+	}, nil
+}
+
+// dirEntries returns the sorted entries of the directory or nil if the
+// directory does not exist. The caller has to hold the read lock.
+func (m *Memory) dirEntries(dir string) []fs.DirEntry {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+
+	entries := map[string]fs.DirEntry{}
+	for name, file := range m.files {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+
+		child, _, isDir := strings.Cut(rest, "/")
+		info := fileInfo{
+			name:    child,
+			size:    int64(len(file.data)),
+			modTime: file.modTime,
+		}
+		if isDir {
+			info = fileInfo{name: child, mode: fs.ModeDir | 0o555}
+		}
+		entries[child] = fs.FileInfoToDirEntry(info)
+	}
+	if len(entries) == 0 && dir != "." {
+		return nil
+	}
+
+	result := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	slices.SortFunc(result, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return result
+}
+
+// fileInfo implements fs.FileInfo for the files and directories of the
+// memory storage.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode | 0o444 }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() any           { return nil }
+
+// openFile is an opened file of the memory storage, it supports seeking
+// which is required for serving it using http.FS.
+type openFile struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openFile) Close() error               { return nil }
+
+// openDir is an opened directory of the memory storage.
+type openDir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openDir) Close() error               { return nil }
+
+func (d *openDir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *openDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	count = min(count, len(remaining))
+	d.offset += count
+	return remaining[:count], nil
+}
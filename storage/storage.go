@@ -0,0 +1,36 @@
+// Package storage provides the backends that the scraped files are written to.
+package storage
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage is a backend that the scraped files are written to. The file paths
+// are file system paths that include the output directory. All methods have
+// to be safe for concurrent use.
+type Storage interface {
+	// WriteFile writes the data to the file, replacing the file if it exists
+	// and creating all missing parent directories.
+	WriteFile(filePath string, data []byte) error
+	// ReadFile returns the content of the file, the returned error matches
+	// fs.ErrNotExist if the file does not exist.
+	ReadFile(filePath string) ([]byte, error)
+	// Exists returns whether the file exists.
+	Exists(filePath string) bool
+	// Remove removes the file, it returns no error if the file does not exist.
+	Remove(filePath string) error
+}
+
+// cleanPath converts a file system path to a slash separated path that is
+// valid for the io/fs package. Absolute paths are converted to relative
+// paths, the root is returned as ".".
+func cleanPath(filePath string) string {
+	p := path.Clean("/" + filepath.ToSlash(filePath))
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "."},
+		{"/", "."},
+		{"example.org/index.html", "example.org/index.html"},
+		{"./example.org/../example.org/index.html", "example.org/index.html"},
+		{"/tmp/out/example.org/index.html", "tmp/out/example.org/index.html"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, cleanPath(test.input), test.input)
+	}
+}
+
+func TestFileSystem(t *testing.T) {
+	st := NewFileSystem()
+	filePath := filepath.Join(t.TempDir(), "example.org", "css", "style.css")
+
+	assert.False(t, st.Exists(filePath))
+	_, err := st.ReadFile(filePath)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, st.WriteFile(filePath, []byte("body {}")))
+	assert.True(t, st.Exists(filePath))
+	data, err := st.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "body {}", string(data))
+
+	require.NoError(t, st.Remove(filePath))
+	assert.False(t, st.Exists(filePath))
+	require.NoError(t, st.Remove(filePath))
+}
+
+func TestMemory(t *testing.T) {
+	st := NewMemory()
+
+	_, err := st.ReadFile("example.org/index.html")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, st.WriteFile("example.org/index.html", []byte("index")))
+	require.NoError(t, st.WriteFile("example.org/css/style.css", []byte("body {}")))
+	require.NoError(t, st.WriteFile("./example.org/index.html", []byte("updated")))
+
+	assert.True(t, st.Exists("example.org/css/style.css"))
+	assert.False(t, st.Exists("example.org/css"))
+	assert.Equal(t, []string{"example.org/css/style.css", "example.org/index.html"}, st.Paths())
+
+	data, err := st.ReadFile("example.org/index.html")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", string(data))
+
+	require.NoError(t, fstest.TestFS(st.FS(), "example.org/index.html", "example.org/css/style.css"))
+
+	require.NoError(t, st.Remove("example.org/css/style.css"))
+	require.NoError(t, st.Remove("example.org/missing.html"))
+	assert.Equal(t, []string{"example.org/index.html"}, st.Paths())
+}
+
+func TestArchive(t *testing.T) {
+	for _, name := range []string{"site.zip", "site.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), name)
+			st, err := NewArchive(filePath, "tmp/out")
+			require.NoError(t, err)
+
+			require.NoError(t, st.WriteFile("tmp/out/example.org/index.html", []byte("first")))
+			require.NoError(t, st.WriteFile("tmp/out/example.org/index.html", []byte("index")))
+			require.NoError(t, st.WriteFile("tmp/out/example.org/img/logo.png", []byte("png")))
+			require.NoError(t, st.WriteFile("tmp/outside.html", []byte("outside")))
+			require.NoError(t, st.Close())
+
+			m, err := OpenArchive(filePath)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"example.org/img/logo.png", "example.org/index.html", "tmp/outside.html"}, m.Paths())
+
+			data, err := fs.ReadFile(m.FS(), "example.org/index.html")
+			require.NoError(t, err)
+			assert.Equal(t, "index", string(data))
+		})
+	}
+
+	_, err := NewArchive("site.rar", "")
+	require.ErrorIs(t, err, errUnsupportedArchive)
+}
+
+func TestCreateFile(t *testing.T) {
+	dir := t.TempDir()
+	storages := map[string]struct {
+		st       Storage
+		filePath string
+	}{
+		"file system": {NewFileSystem(), filepath.Join(dir, "example.org", "video.mp4")},
+		"memory":      {NewMemory(), "example.org/video.mp4"},
+	}
+
+	for name, test := range storages {
+		t.Run(name, func(t *testing.T) {
+			file, err := CreateFile(test.st, test.filePath, nil)
+			require.NoError(t, err)
+			_, err = file.Write([]byte("first"))
+			require.NoError(t, err)
+			require.NoError(t, file.Abort())
+			assert.False(t, test.st.Exists(test.filePath))
+			assert.False(t, test.st.Exists(test.filePath+PartialFileSuffix))
+
+			file, err = CreateFile(test.st, test.filePath, nil)
+			require.NoError(t, err)
+			for _, chunk := range []string{"video", " data"} {
+				_, err = file.Write([]byte(chunk))
+				require.NoError(t, err)
+			}
+			assert.False(t, test.st.Exists(test.filePath))
+			require.NoError(t, file.Commit())
+			assert.False(t, test.st.Exists(test.filePath+PartialFileSuffix))
+
+			data, err := test.st.ReadFile(test.filePath)
+			require.NoError(t, err)
+			assert.Equal(t, "video data", string(data))
+		})
+	}
+}
+
+func TestResumeFile(t *testing.T) {
+	st := NewFileSystem()
+	filePath := filepath.Join(t.TempDir(), "example.org", "image.iso")
+
+	_, err := OpenPartialFile(st, filePath)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	file, err := CreateFile(st, filePath, []byte(`{"etag":"abc"}`))
+	require.NoError(t, err)
+	_, err = file.Write([]byte("first "))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	assert.False(t, st.Exists(filePath))
+
+	partial, err := OpenPartialFile(st, filePath)
+	require.NoError(t, err)
+	assert.Equal(t, PartialFile{Size: 6, Info: []byte(`{"etag":"abc"}`)}, partial)
+
+	var existing bytes.Buffer
+	file, err = ResumeFile(st, filePath, &existing)
+	require.NoError(t, err)
+	assert.Equal(t, "first ", existing.String())
+	_, err = file.Write([]byte("second"))
+	require.NoError(t, err)
+	require.NoError(t, file.Commit())
+
+	data, err := st.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(data))
+	assert.False(t, st.Exists(filePath+PartialFileSuffix))
+	assert.False(t, st.Exists(filePath+PartialInfoSuffix))
+
+	_, err = OpenPartialFile(NewMemory(), "example.org/image.iso")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
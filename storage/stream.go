@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// PartialFileSuffix is appended to the path of a file that is written by
+	// CreateFile until it gets committed.
+	PartialFileSuffix = ".part"
+	// PartialInfoSuffix is appended to the path of a file to store the info
+	// of its partial file.
+	PartialInfoSuffix = ".part.info"
+)
+
+// FileCreator is implemented by storages that can write a file as a stream
+// without keeping its content in memory. Partial files of interrupted
+// downloads are kept to resume them.
+type FileCreator interface {
+	// CreateFile creates a partial file for the file path, replacing an
+	// existing partial file. The info is stored with the partial file and
+	// is returned by PartialFile, nil info stores none. The written data
+	// replaces the file at the file path once the file is committed.
+	CreateFile(filePath string, info []byte) (File, error)
+	// PartialFile returns the partial file of the file path, the returned
+	// error matches fs.ErrNotExist if there is none.
+	PartialFile(filePath string) (PartialFile, error)
+	// ResumeFile opens the partial file of the file path for appending.
+	// The data of the partial file is copied to w first, which allows
+	// hashing the complete file.
+	ResumeFile(filePath string, w io.Writer) (File, error)
+}
+
+// PartialFile is the partial file of an interrupted download.
+type PartialFile struct {
+	Size int64  // size of the written data
+	Info []byte // info that was stored with the partial file
+}
+
+// File is a file that is written as a stream, it has to be either committed,
+// aborted or closed.
+type File interface {
+	Write(p []byte) (int, error)
+	// Commit closes the file and replaces the file at the file path with it.
+	Commit() error
+	// Abort closes the file and discards the written data.
+	Abort() error
+	// Close closes the file and keeps the written data as partial file if
+	// the storage supports resuming it, otherwise the data is discarded.
+	Close() error
+}
+
+// CreateFile creates a file that is written as a stream. Storages that do
+// not implement FileCreator get the data written on commit.
+func CreateFile(st Storage, filePath string, info []byte) (File, error) {
+	if creator, ok := st.(FileCreator); ok {
+		file, err := creator.CreateFile(filePath, info)
+		if err != nil {
+			return nil, fmt.Errorf("creating file: %w", err)
+		}
+		return file, nil
+	}
+	return &bufferedFile{storage: st, filePath: filePath}, nil
+}
+
+// OpenPartialFile returns the partial file of the file path, the returned
+// error matches fs.ErrNotExist if there is none or the storage does not
+// support partial files.
+func OpenPartialFile(st Storage, filePath string) (PartialFile, error) {
+	creator, ok := st.(FileCreator)
+	if !ok {
+		return PartialFile{}, &fs.PathError{Op: "open", Path: filePath + PartialFileSuffix, Err: fs.ErrNotExist}
+	}
+
+	partial, err := creator.PartialFile(filePath)
+	if err != nil {
+		return PartialFile{}, fmt.Errorf("opening partial file: %w", err)
+	}
+	return partial, nil
+}
+
+// ResumeFile opens the partial file of the file path for appending, the
+// data of the partial file is copied to w first.
+func ResumeFile(st Storage, filePath string, w io.Writer) (File, error) {
+	creator, ok := st.(FileCreator)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: filePath + PartialFileSuffix, Err: fs.ErrNotExist}
+	}
+
+	file, err := creator.ResumeFile(filePath, w)
+	if err != nil {
+		return nil, fmt.Errorf("resuming file: %w", err)
+	}
+	return file, nil
+}
+
+// bufferedFile buffers the written data in memory and writes it to the
+// storage on commit.
+type bufferedFile struct {
+	storage  Storage
+	filePath string
+	buf      bytes.Buffer
+}
+
+func (f *bufferedFile) Write(p []byte) (int, error) {
+	return f.buf.Write(p) // nolint: wrapcheck
+}
+
+func (f *bufferedFile) Commit() error {
+	return f.storage.WriteFile(f.filePath, f.buf.Bytes()) // nolint: wrapcheck
+}
+
+func (f *bufferedFile) Abort() error {
+	f.buf.Reset()
+	return nil
+}
+
+func (f *bufferedFile) Close() error {
+	return f.Abort()
+}
+
+// CreateFile creates the file with the partial file suffix next to the file
+// path and all missing parent directories. Committing the file renames it
+// to the file path, which replaces an existing file atomically.
+func (f *FileSystem) CreateFile(filePath string, info []byte) (File, error) {
+	if dir := filepath.Dir(filePath); dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("creating directory '%s': %w", dir, err)
+		}
+	}
+
+	infoPath := filePath + PartialInfoSuffix
+	if info == nil {
+		if err := os.Remove(infoPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("removing partial file info: %w", err)
+		}
+	} else if err := os.WriteFile(infoPath, info, 0o644); err != nil {
+		return nil, fmt.Errorf("writing partial file info: %w", err)
+	}
+
+	partialPath := filePath + PartialFileSuffix
+	file, err := os.Create(partialPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating file '%s': %w", partialPath, err)
+	}
+
+	return &partialFile{
+		file:     file,
+		filePath: filePath,
+	}, nil
+}
+
+// PartialFile returns the partial file of the file path.
+func (f *FileSystem) PartialFile(filePath string) (PartialFile, error) {
+	stat, err := os.Stat(filePath + PartialFileSuffix)
+	if err != nil {
+		return PartialFile{}, fmt.Errorf("reading partial file: %w", err)
+	}
+
+	info, err := os.ReadFile(filePath + PartialInfoSuffix)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return PartialFile{}, fmt.Errorf("reading partial file info: %w", err)
+	}
+
+	return PartialFile{
+		Size: stat.Size(),
+		Info: info,
+	}, nil
+}
+
+// ResumeFile opens the partial file of the file path for appending.
+func (f *FileSystem) ResumeFile(filePath string, w io.Writer) (File, error) {
+	partialPath := filePath + PartialFileSuffix
+	file, err := os.OpenFile(partialPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening file '%s': %w", partialPath, err)
+	}
+
+	// the file offset is at the end of the data after copying it
+	if _, err := io.Copy(w, file); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("reading file '%s': %w", partialPath, err)
+	}
+
+	return &partialFile{
+		file:     file,
+		filePath: filePath,
+	}, nil
+}
+
+// partialFile is a file of the local file system that is written to a
+// partial file first.
+type partialFile struct {
+	file     *os.File
+	filePath string
+}
+
+func (f *partialFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("writing to file: %w", err)
+	}
+	return n, nil
+}
+
+func (f *partialFile) Commit() error {
+	if err := f.file.Close(); err != nil {
+		f.remove()
+		return fmt.Errorf("closing file: %w", err)
+	}
+	if err := os.Rename(f.file.Name(), f.filePath); err != nil {
+		f.remove()
+		return fmt.Errorf("renaming file: %w", err)
+	}
+	_ = os.Remove(f.filePath + PartialInfoSuffix)
+	return nil
+}
+
+func (f *partialFile) Abort() error {
+	// nolint: wrapcheck
+	_ = f.file.Close() // try to close and remove file but return the first error
+	if err := os.Remove(f.file.Name()); err != nil {
+		return fmt.Errorf("removing file: %w", err)
+	}
+	_ = os.Remove(f.filePath + PartialInfoSuffix)
+	return nil
+}
+
+func (f *partialFile) Close() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+	return nil
+}
+
+// remove removes the partial file and its info.
+func (f *partialFile) remove() {
+	_ = os.Remove(f.file.Name())
+	_ = os.Remove(f.filePath + PartialInfoSuffix)
+}
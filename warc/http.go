@@ -0,0 +1,75 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RequestRecord returns a request record for the HTTP request.
+func RequestRecord(req *http.Request, date time.Time) *Record {
+	header := &bytes.Buffer{}
+	host := req.Host
+	if host == "" { // requests of followed redirects only set the URL
+		host = req.URL.Host
+	}
+	_, _ = fmt.Fprintf(header, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	_, _ = fmt.Fprintf(header, "Host: %s\r\n", host)
+	_ = req.Header.Write(header)
+	header.WriteString("\r\n")
+
+	return &Record{
+		Type:        TypeRequest,
+		Date:        date,
+		TargetURI:   req.URL.String(),
+		ContentType: ContentTypeHTTPRequest,
+		Header:      header.Bytes(),
+	}
+}
+
+// ResponseRecord returns a response record for the HTTP response and the
+// response body that was read from it.
+func ResponseRecord(resp *http.Response, body []byte, date time.Time) *Record {
+	header := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(header, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	_ = resp.Header.Write(header)
+	header.WriteString("\r\n")
+
+	return &Record{
+		Type:        TypeResponse,
+		Date:        date,
+		TargetURI:   resp.Request.URL.String(),
+		ContentType: ContentTypeHTTPResponse,
+		Header:      header.Bytes(),
+		Payload:     body,
+	}
+}
+
+// WriteExchange writes the request and response records of an HTTP exchange.
+func (w *Writer) WriteExchange(resp *http.Response, body []byte, date time.Time) error {
+	return w.writeExchange(ResponseRecord(resp, body, date), resp.Request, date)
+}
+
+// WriteStreamedExchange writes the request and response records of an HTTP
+// exchange whose response body is read from the reader, like a temporary
+// file that the body was spooled to.
+func (w *Writer) WriteStreamedExchange(resp *http.Response, body io.ReadSeeker, date time.Time) error {
+	response := ResponseRecord(resp, nil, date)
+	response.PayloadReader = body
+	return w.writeExchange(response, resp.Request, date)
+}
+
+func (w *Writer) writeExchange(response *Record, req *http.Request, date time.Time) error {
+	request := RequestRecord(req, date)
+
+	id, err := NewRecordID()
+	if err != nil {
+		return err
+	}
+	response.ID = id
+	request.ConcurrentTo = id
+
+	return w.WriteRecords(response, request)
+}
@@ -0,0 +1,300 @@
+// Package warc provides a writer for WARC 1.1 files that stores every record
+// as a separate gzip member and rotates the files based on their size.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1" // nolint: gosec // SHA-1 is the digest algorithm commonly used by WARC tools
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RecordType is the type of WARC record.
+type RecordType string
+
+// nolint: revive
+const (
+	TypeWarcinfo RecordType = "warcinfo"
+	TypeRequest  RecordType = "request"
+	TypeResponse RecordType = "response"
+)
+
+// nolint: revive
+const (
+	ContentTypeHTTPRequest  = "application/http;msgtype=request"
+	ContentTypeHTTPResponse = "application/http;msgtype=response"
+	ContentTypeWarcFields   = "application/warc-fields"
+)
+
+// DefaultMaxFileSize is the file size after which a new WARC file is started
+// if no maximum size is configured.
+const DefaultMaxFileSize = 1 << 30
+
+// FileExtension is the file extension of the written WARC files.
+const FileExtension = ".warc.gz"
+
+// Record is a single WARC record.
+type Record struct {
+	Type         RecordType
+	ID           string // generated if empty
+	Date         time.Time
+	TargetURI    string
+	ContentType  string
+	ConcurrentTo string // ID of a record that was created at the same time
+
+	// Header and Payload form the record block, the payload digest is only
+	// calculated for request and response records. PayloadReader replaces
+	// Payload if it is set, it is read twice to calculate the digests before
+	// the record is written, which avoids keeping large payloads in memory.
+	Header        []byte
+	Payload       []byte
+	PayloadReader io.ReadSeeker
+}
+
+// Config contains the configuration of a WARC writer.
+type Config struct {
+	Directory   string // directory to write the WARC files to
+	Prefix      string // file name prefix of the WARC files
+	MaxFileSize int64  // size after which a new file is started, 0 for the default
+	Software    string // software name written to the warcinfo records
+}
+
+// Writer writes WARC records to gzip compressed WARC files.
+type Writer struct {
+	cfg Config
+
+	mu         sync.Mutex
+	file       *os.File
+	fileName   string
+	fileSize   int64
+	serial     int
+	warcinfoID string
+}
+
+// NewWriter returns a new WARC writer, the first file is created when the
+// first record is written.
+func NewWriter(cfg Config) *Writer {
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = DefaultMaxFileSize
+	}
+	return &Writer{
+		cfg: cfg,
+	}
+}
+
+// WriteRecords writes the records to the current WARC file. All records
+// passed in one call are written to the same file, a new file is started
+// once the current file reached the maximum file size.
+func (w *Writer) WriteRecords(records ...*Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.fileSize >= w.cfg.MaxFileSize {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		if err := w.writeRecord(record, "WARC-Warcinfo-ID", w.warcinfoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the current WARC file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+func (w *Writer) openFile() error {
+	if err := os.MkdirAll(w.cfg.Directory, os.ModePerm); err != nil {
+		return fmt.Errorf("creating WARC directory: %w", err)
+	}
+
+	w.serial++
+	w.fileName = fmt.Sprintf("%s-%s-%05d%s", w.cfg.Prefix,
+		time.Now().UTC().Format("20060102150405"), w.serial, FileExtension)
+
+	f, err := os.Create(filepath.Join(w.cfg.Directory, w.fileName))
+	if err != nil {
+		return fmt.Errorf("creating WARC file: %w", err)
+	}
+	w.file = f
+	w.fileSize = 0
+
+	info := &Record{
+		Type:        TypeWarcinfo,
+		Date:        time.Now(),
+		ContentType: ContentTypeWarcFields,
+		Payload: []byte("software: " + w.cfg.Software + "\r\n" +
+			"format: WARC File Format 1.1\r\n" +
+			"conformsTo: https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"),
+	}
+	if err := w.writeRecord(info, "WARC-Filename", w.fileName); err != nil {
+		return err
+	}
+	w.warcinfoID = info.ID
+	return nil
+}
+
+// writeRecord writes a record to the current file. The extra fields are
+// written as additional header fields. A partially written record is
+// truncated to keep the file readable.
+func (w *Writer) writeRecord(record *Record, extraFields ...string) error {
+	counter := &countingWriter{w: w.file}
+	err := encodeRecord(counter, record, extraFields...)
+	if err != nil && counter.n > 0 {
+		if truncErr := w.file.Truncate(w.fileSize); truncErr != nil {
+			return errors.Join(err, fmt.Errorf("truncating WARC file: %w", truncErr))
+		}
+		if _, seekErr := w.file.Seek(w.fileSize, io.SeekStart); seekErr != nil {
+			return errors.Join(err, fmt.Errorf("seeking WARC file: %w", seekErr))
+		}
+		return err
+	}
+	w.fileSize += counter.n
+	return err
+}
+
+func (w *Writer) closeFile() error {
+	err := w.file.Close()
+	w.file = nil
+	w.warcinfoID = ""
+	if err != nil {
+		return fmt.Errorf("closing WARC file: %w", err)
+	}
+	return nil
+}
+
+// encodeRecord writes the gzip compressed record to the writer.
+func encodeRecord(w io.Writer, record *Record, extraFields ...string) error {
+	if record.ID == "" {
+		id, err := NewRecordID()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+	}
+
+	payload := record.PayloadReader
+	if payload == nil {
+		payload = bytes.NewReader(record.Payload)
+	}
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking WARC record payload: %w", err)
+	}
+	blockHash, payloadHash := sha1.New(), sha1.New() // nolint: gosec
+	blockHash.Write(record.Header)
+	payloadSize, err := io.Copy(io.MultiWriter(blockHash, payloadHash), payload)
+	if err != nil {
+		return fmt.Errorf("reading WARC record payload: %w", err)
+	}
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking WARC record payload: %w", err)
+	}
+
+	header := &bytes.Buffer{}
+	header.WriteString("WARC/1.1\r\n")
+	writeField(header, "WARC-Type", string(record.Type))
+	writeField(header, "WARC-Record-ID", record.ID)
+	writeField(header, "WARC-Date", record.Date.UTC().Format(time.RFC3339))
+	writeField(header, "WARC-Target-URI", record.TargetURI)
+	writeField(header, "WARC-Concurrent-To", record.ConcurrentTo)
+	for i := 0; i+1 < len(extraFields); i += 2 {
+		writeField(header, extraFields[i], extraFields[i+1])
+	}
+	writeField(header, "Content-Type", record.ContentType)
+	writeField(header, "WARC-Block-Digest", hashDigest(blockHash))
+	if record.Type == TypeRequest || record.Type == TypeResponse {
+		writeField(header, "WARC-Payload-Digest", hashDigest(payloadHash))
+	}
+	writeField(header, "Content-Length", strconv.FormatInt(int64(len(record.Header))+payloadSize, 10))
+	header.WriteString("\r\n")
+
+	gz := gzip.NewWriter(w)
+	for _, data := range [][]byte{header.Bytes(), record.Header} {
+		if _, err := gz.Write(data); err != nil {
+			return fmt.Errorf("writing WARC record: %w", err)
+		}
+	}
+	written, err := io.Copy(gz, payload)
+	if err == nil && written != payloadSize {
+		err = fmt.Errorf("payload changed from %d to %d bytes", payloadSize, written)
+	}
+	if err != nil {
+		return fmt.Errorf("writing WARC record payload: %w", err)
+	}
+	if _, err := gz.Write([]byte("\r\n\r\n")); err != nil {
+		return fmt.Errorf("writing WARC record: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing WARC record: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes that are written to the wrapped writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func writeField(w io.StringWriter, name, value string) {
+	if value == "" {
+		return
+	}
+	_, _ = w.WriteString(name + ": " + value + "\r\n")
+}
+
+// Digest returns the SHA-1 digest of the data in the format used by WARC files.
+func Digest(data []byte) string {
+	sum := sha1.Sum(data) // nolint: gosec
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// hashDigest returns the digest of the SHA-1 hash in the format used by WARC
+// files.
+func hashDigest(h hash.Hash) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// NewRecordID returns a new random record ID.
+func NewRecordID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating record ID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,137 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExchange(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWriter(Config{
+		Directory:   dir,
+		Prefix:      "test",
+		MaxFileSize: 1,
+		Software:    "goscrape",
+	})
+
+	u, err := url.Parse("https://example.org/page?x=1")
+	require.NoError(t, err)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{"User-Agent": []string{"test"}},
+	}
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Request:    req,
+	}
+	body := []byte("<html></html>")
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, w.WriteExchange(resp, body, date))
+	require.NoError(t, w.WriteExchange(resp, body, date))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*"+FileExtension))
+	require.NoError(t, err)
+	require.Len(t, files, 2) // rotated after every exchange
+
+	content := readGzipMembers(t, files[0])
+	records := strings.Split(content, "\r\n\r\nWARC/1.1\r\n")
+	require.Len(t, records, 3)
+
+	assert.Contains(t, records[0], "WARC-Type: warcinfo\r\n")
+	assert.Contains(t, records[0], "WARC-Filename: "+filepath.Base(files[0])+"\r\n")
+	warcinfoID := regexp.MustCompile(`WARC-Record-ID: (\S+)`).FindStringSubmatch(records[0])[1]
+
+	response := records[1]
+	assert.Contains(t, response, "WARC-Type: response\r\n")
+	assert.Contains(t, response, "WARC-Date: 2024-01-02T03:04:05Z\r\n")
+	assert.Contains(t, response, "WARC-Target-URI: https://example.org/page?x=1\r\n")
+	assert.Contains(t, response, "WARC-Warcinfo-ID: "+warcinfoID+"\r\n")
+	assert.Contains(t, response, "WARC-Payload-Digest: "+Digest(body)+"\r\n")
+	assert.Contains(t, response, "\r\n\r\nHTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html></html>")
+	responseID := regexp.MustCompile(`WARC-Record-ID: (\S+)`).FindStringSubmatch(response)[1]
+
+	request := records[2]
+	assert.Contains(t, request, "WARC-Type: request\r\n")
+	assert.Contains(t, request, "WARC-Concurrent-To: "+responseID+"\r\n")
+	assert.Contains(t, request, "GET /page?x=1 HTTP/1.1\r\nHost: example.org\r\nUser-Agent: test\r\n")
+}
+
+func TestWriteStreamedExchange(t *testing.T) {
+	u, err := url.Parse("https://example.org/file.bin")
+	require.NoError(t, err)
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/octet-stream"}},
+		Request:    &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: http.Header{}},
+	}
+	body := bytes.Repeat([]byte("0123456789"), 1000)
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	contents := make([]string, 2)
+	for i, write := range []func(w *Writer) error{
+		func(w *Writer) error { return w.WriteExchange(resp, body, date) },
+		func(w *Writer) error {
+			spool := bytes.NewReader(body)
+			_, _ = spool.Seek(0, io.SeekEnd) // like a file that the body was just written to
+			return w.WriteStreamedExchange(resp, spool, date)
+		},
+	} {
+		dir := t.TempDir()
+		w := NewWriter(Config{Directory: dir, Prefix: "test"})
+		require.NoError(t, write(w))
+		require.NoError(t, w.Close())
+
+		files, err := filepath.Glob(filepath.Join(dir, "test-*"+FileExtension))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		content := readGzipMembers(t, files[0])
+		// record IDs and file names differ between the writers
+		content = regexp.MustCompile(`(WARC-Record-ID|WARC-Warcinfo-ID|WARC-Concurrent-To|WARC-Filename): \S+`).
+			ReplaceAllString(content, "$1")
+		contents[i] = content
+	}
+
+	assert.Contains(t, contents[1], "WARC-Payload-Digest: "+Digest(body)+"\r\n")
+	assert.Contains(t, contents[1], "\r\n\r\n"+string(body)+"\r\n\r\n")
+	assert.Equal(t, contents[0], contents[1])
+}
+
+func TestDigest(t *testing.T) {
+	assert.Equal(t, "sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ", Digest([]byte{}))
+}
+
+func readGzipMembers(t *testing.T, fileName string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader) // reads all gzip members
+	require.NoError(t, err)
+	return string(content)
+}